@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+)
+
+const usage = `usage:
+  chirpy                            run the server
+  chirpy grant-role <email> <role>  set a user's role (%s)`
+
+// runCommand runs the admin command in args, such as granting the first
+// admin their role before anyone can do it through the API
+func runCommand(ctx context.Context, db *database.Queries, args []string) error {
+	switch args[0] {
+	case "grant-role":
+		if len(args) != 3 {
+			return fmt.Errorf(usage, strings.Join(auth.Roles, ", "))
+		}
+		return grantRole(ctx, db, args[1], args[2])
+	default:
+		return fmt.Errorf(usage, strings.Join(auth.Roles, ", "))
+	}
+}
+
+func grantRole(ctx context.Context, db *database.Queries, email, role string) error {
+	if !auth.ValidRole(role) {
+		return fmt.Errorf("unknown role %q, want one of %s", role, strings.Join(auth.Roles, ", "))
+	}
+	user, err := db.GetUserByEmail(ctx, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return fmt.Errorf("finding %s: %w", email, err)
+	}
+	user, err = db.UpdateUserRole(ctx, database.UpdateUserRoleParams{ID: user.ID, Role: role})
+	if err != nil {
+		return fmt.Errorf("updating role: %w", err)
+	}
+	fmt.Printf("%s is now %s\n", user.Email, user.Role)
+	return nil
+}
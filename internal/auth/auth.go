@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -15,8 +17,19 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Issuer and audience of HS256 access tokens, the same defaults a KeySet
+// gets in main
+const (
+	secretIssuer   = "chirpy"
+	secretAudience = "chirpy-api"
+)
+
+// PasswordCost is the bcrypt cost new password hashes are made with.
+// Raising it upgrades existing hashes as their owners log in.
+var PasswordCost = 10
+
 func HashPassword(password string)(string,error)  {
-	hashByte,err := bcrypt.GenerateFromPassword([]byte(password),10);
+	hashByte,err := bcrypt.GenerateFromPassword([]byte(password),PasswordCost);
 	if err != nil{
 	fmt.Printf("Error occured generating HashedPAssword: %v",err);
 		return "",err
@@ -30,16 +43,68 @@ func CheckPasswordHash(hash,password string)error  {
 return err
 }
 
+// NeedsRehash reports whether hash was made with a lower cost than
+// PasswordCost, so it should be replaced once the password is known
+func NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost < PasswordCost
+}
+
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error){
-	
-	expiresAt := time.Now().Add(expiresIn * time.Second)
-	
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer: "chirpy",
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		Subject: userID.String(),
-	});
+	return MakeSessionJWT(userID, uuid.Nil, tokenSecret, expiresIn)
+}
+
+// sessionClaims carries the login session an access token was issued for
+// and the user's role or, for tokens issued to an OAuth client, the client
+// and its scopes
+type sessionClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+}
+
+// AccessClaims describes an access token. Tokens from logging in have no
+// ClientID and carry every scope; tokens issued to an OAuth client carry
+// the client's id, the grant as SessionID, and only the scopes granted.
+// Role is the user's role when a login token was issued.
+type AccessClaims struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	Role      string
+	ClientID  string
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// newSessionClaims fills in the custom claims for c
+func newSessionClaims(c AccessClaims, registered jwt.RegisteredClaims) sessionClaims {
+	claims := sessionClaims{RegisteredClaims: registered, Role: c.Role, ClientID: c.ClientID, Scope: FormatScope(c.Scopes)}
+	if c.SessionID != uuid.Nil {
+		claims.SessionID = c.SessionID.String()
+	}
+	return claims
+}
+
+// MakeSessionJWT is MakeJWT for a token tied to a login session; a Nil
+// sessionID leaves the sid claim out
+func MakeSessionJWT(userID, sessionID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error){
+	return MakeAccessJWT(AccessClaims{UserID: userID, SessionID: sessionID}, tokenSecret, expiresIn * time.Second)
+}
+
+// MakeAccessJWT issues an HS256 access token described by claims, valid for
+// expiresIn. Unlike MakeSessionJWT, expiresIn is a real duration.
+func MakeAccessJWT(claims AccessClaims, tokenSecret string, expiresIn time.Duration) (string, error){
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newSessionClaims(claims, jwt.RegisteredClaims{
+		Issuer: secretIssuer,
+		Audience: jwt.ClaimStrings{secretAudience},
+		IssuedAt: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		Subject: claims.UserID.String(),
+	}))
 
 	signedToken,err := token.SignedString([]byte(tokenSecret));
 	if err != nil{
@@ -49,30 +114,70 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString,tokenSecret string)(uuid.UUID,error)  {
-	
-	type MyCustomClaims struct {
-		jwt.RegisteredClaims
+	userID, _, err := ValidateSessionJWT(tokenString, tokenSecret)
+	return userID, err
+}
+
+// ValidateSessionJWT is ValidateJWT that also returns the token's session,
+// or uuid.Nil for tokens issued without one
+func ValidateSessionJWT(tokenString,tokenSecret string)(uuid.UUID,uuid.UUID,error)  {
+	claims, err := ParseAccessJWT(tokenString, tokenSecret)
+	if err != nil{
+		return uuid.Nil,uuid.Nil,err
 	}
+	return claims.UserID, claims.SessionID, nil
+}
+
+// ParseAccessJWT checks an HS256 access token and returns what it claims
+func ParseAccessJWT(tokenString,tokenSecret string)(AccessClaims,error)  {
 	
-	token,err := jwt.ParseWithClaims(tokenString,&MyCustomClaims{},func(t *jwt.Token) (interface{}, error) {
+	// only HS256 is accepted, so a token can't pick a weaker or different
+	// algorithm to be checked with, and like KeySet.ParseAccessJWT a token
+	// without an expiry never validates
+	token,err := jwt.ParseWithClaims(tokenString,&sessionClaims{},func(t *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret),nil
-	});
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(secretIssuer),
+		jwt.WithAudience(secretAudience),
+		jwt.WithExpirationRequired(),
+	);
 
 
 	if err != nil{
 		fmt.Printf("Error: %v\n",err)
-		return uuid.Nil,err
-	}else if claims,ok := token.Claims.(*MyCustomClaims);ok{
-		uid,err := uuid.Parse(claims.RegisteredClaims.Subject)
-		if err != nil{
-			//fmt.Printf("Error occurred converting string to uuid %v",err)
-			return uuid.Nil, fmt.Errorf("invalid UUID in token: %w", err)
-		}
-		return uid,nil
+		return AccessClaims{},err
+	}else if claims,ok := token.Claims.(*sessionClaims);ok{
+		return parseSessionClaims(claims)
 	}else{
 		//log.Fatal("Unknown claims type,cannot proceed")
-		return uuid.Nil,errors.New("unkown Claims")
+		return AccessClaims{},errors.New("unkown Claims")
+	}
+}
+
+// parseSessionClaims pulls the user, session and client out of validated
+// claims
+func parseSessionClaims(claims *sessionClaims) (AccessClaims, error) {
+	uid,err := uuid.Parse(claims.RegisteredClaims.Subject)
+	if err != nil{
+		//fmt.Printf("Error occurred converting string to uuid %v",err)
+		return AccessClaims{}, fmt.Errorf("invalid UUID in token: %w", err)
+	}
+	sid := uuid.Nil
+	if claims.SessionID != "" {
+		sid,err = uuid.Parse(claims.SessionID)
+		if err != nil{
+			return AccessClaims{}, fmt.Errorf("invalid session in token: %w", err)
+		}
 	}
+	out := AccessClaims{UserID: uid, SessionID: sid, Role: claims.Role, ClientID: claims.ClientID, Scopes: ParseScope(claims.Scope)}
+	if claims.IssuedAt != nil {
+		out.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		out.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return out, nil
 }
 
 func GetBearerToken(headers http.Header)(string,error)  {
@@ -94,6 +199,15 @@ func MakeRefreshToken()  (string,error){
 }
 
 
+// HashRefreshToken returns the keyed hash refresh tokens are stored and
+// looked up by. It is deterministic so the hash can be indexed, and keyed so
+// a copy of the table alone can't be used to check guesses.
+func HashRefreshToken(token, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func GetAPIKey(headers http.Header)(string, error){
 	authHeader := headers.Get("Authorization")
 	if authHeader == ""{
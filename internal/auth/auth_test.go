@@ -6,6 +6,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHashPassword(t *testing.T) {
@@ -25,6 +26,17 @@ func TestHashPassword(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestNeedsRehash(t *testing.T) {
+	hash, err := HashPassword("securePassword123")
+	assert.NoError(t, err)
+	assert.False(t, NeedsRehash(hash))
+
+	defer func(cost int) { PasswordCost = cost }(PasswordCost)
+	PasswordCost++
+	assert.True(t, NeedsRehash(hash))
+	assert.False(t, NeedsRehash("not a bcrypt hash"))
+}
+
 func TestMakeAndValidateJWT(t *testing.T) {
 	userID := uuid.New()
 	tokenSecret := "test-secret-key"
@@ -48,6 +60,7 @@ func TestExpiredToken(t *testing.T) {
 	// Create a token that expires immediately
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
 		Issuer:    "chirpy",
+		Audience:  jwt.ClaimStrings{"chirpy-api"},
 		IssuedAt:  jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-1 * time.Hour)), // Expired 1 hour ago
 		Subject:   userID.String(),
@@ -90,6 +103,7 @@ func TestInvalidUUID(t *testing.T) {
 	// Create a token with an invalid UUID as subject
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
 		Issuer:    "chirpy",
+		Audience:  jwt.ClaimStrings{"chirpy-api"},
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Hour)),
 		Subject:   "not-a-valid-uuid",
@@ -101,4 +115,73 @@ func TestInvalidUUID(t *testing.T) {
 	// Validate should fail due to invalid UUID
 	_, err = ValidateJWT(signedToken, tokenSecret)
 	assert.Error(t, err)
-}
\ No newline at end of file
+}
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	assert.NoError(t, err)
+
+	hash := HashRefreshToken(token, "key")
+	assert.Len(t, hash, 64)
+	assert.NotEqual(t, token, hash)
+	assert.Equal(t, hash, HashRefreshToken(token, "key"))
+	assert.NotEqual(t, hash, HashRefreshToken(token, "other key"))
+}
+
+func TestSessionJWT(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+
+	token, err := MakeSessionJWT(userID, sessionID, "secret", 3600)
+	assert.NoError(t, err)
+	gotUser, gotSession, err := ValidateSessionJWT(token, "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, userID, gotUser)
+	assert.Equal(t, sessionID, gotSession)
+
+	// tokens without a session still validate
+	token, err = MakeJWT(userID, "secret", 3600)
+	assert.NoError(t, err)
+	_, gotSession, err = ValidateSessionJWT(token, "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Nil, gotSession)
+}
+
+func TestParseAccessJWTRequiresClaims(t *testing.T) {
+	tokenSecret := "test-secret-key"
+	valid := jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Audience:  jwt.ClaimStrings{"chirpy-api"},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   uuid.New().String(),
+	}
+	sign := func(claims jwt.RegisteredClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(tokenSecret))
+		require.NoError(t, err)
+		return token
+	}
+
+	_, err := ParseAccessJWT(sign(valid), tokenSecret)
+	assert.NoError(t, err)
+
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+	_, err = ParseAccessJWT(sign(noExpiry), tokenSecret)
+	assert.ErrorIs(t, err, jwt.ErrTokenRequiredClaimMissing)
+
+	otherIssuer := valid
+	otherIssuer.Issuer = "someone-else"
+	_, err = ParseAccessJWT(sign(otherIssuer), tokenSecret)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+
+	noAudience := valid
+	noAudience.Audience = nil
+	_, err = ParseAccessJWT(sign(noAudience), tokenSecret)
+	assert.ErrorIs(t, err, jwt.ErrTokenRequiredClaimMissing)
+
+	otherAudience := valid
+	otherAudience.Audience = jwt.ClaimStrings{"another-api"}
+	_, err = ParseAccessJWT(sign(otherAudience), tokenSecret)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for signing or verifying
+const minRSAKeyBits = 2048
+
+var ErrUnknownKey = errors.New("token signed with an unknown key")
+
+// Key is one asymmetric JWT key. Only the signing key needs Private; keys
+// kept around to verify tokens issued before a rotation need only Public.
+type Key struct {
+	ID      string
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// Method is the JWT signing method for the key's type: EdDSA for Ed25519
+// and RS256 for RSA
+func (k Key) Method() (jwt.SigningMethod, error) {
+	switch pub := k.Public.(type) {
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("key %s: RSA keys must be at least %d bits", k.ID, minRSAKeyBits)
+		}
+		return jwt.SigningMethodRS256, nil
+	}
+	return nil, fmt.Errorf("key %s: unsupported key type %T", k.ID, k.Public)
+}
+
+// KeySet signs access tokens with one key and verifies them with any of its
+// keys, picked by the token's kid header. Tokens must use the algorithm of
+// that key and carry the expected issuer and audience.
+type KeySet struct {
+	Issuer   string
+	Audience string
+	signing  Key
+	keys     map[string]Key
+	methods  map[string]jwt.SigningMethod
+}
+
+// NewKeySet returns a KeySet that signs with signing and also accepts
+// tokens signed by any of verifyOnly
+func NewKeySet(issuer, audience string, signing Key, verifyOnly ...Key) (*KeySet, error) {
+	if signing.Private == nil {
+		return nil, fmt.Errorf("signing key %s has no private key", signing.ID)
+	}
+	ks := &KeySet{
+		Issuer:   issuer,
+		Audience: audience,
+		signing:  signing,
+		keys:     map[string]Key{},
+		methods:  map[string]jwt.SigningMethod{},
+	}
+	for _, key := range append([]Key{signing}, verifyOnly...) {
+		if key.ID == "" {
+			return nil, errors.New("every key needs an id")
+		}
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %s", key.ID)
+		}
+		method, err := key.Method()
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[key.ID] = key
+		ks.methods[key.ID] = method
+	}
+	return ks, nil
+}
+
+// MakeJWT issues an access token for userID, tied to sessionID unless it
+// is uuid.Nil, valid for expiresIn
+func (ks *KeySet) MakeJWT(userID, sessionID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return ks.MakeAccessJWT(AccessClaims{UserID: userID, SessionID: sessionID}, expiresIn)
+}
+
+// MakeAccessJWT issues an access token described by claims, valid for
+// expiresIn
+func (ks *KeySet) MakeAccessJWT(c AccessClaims, expiresIn time.Duration) (string, error) {
+	now := time.Now()
+	claims := newSessionClaims(c, jwt.RegisteredClaims{
+		Issuer:    ks.Issuer,
+		Audience:  jwt.ClaimStrings{ks.Audience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		Subject:   c.UserID.String(),
+	})
+	token := jwt.NewWithClaims(ks.methods[ks.signing.ID], claims)
+	token.Header["kid"] = ks.signing.ID
+	return token.SignedString(ks.signing.Private)
+}
+
+// ValidateJWT checks tokenString and returns its user and session
+func (ks *KeySet) ValidateJWT(tokenString string) (uuid.UUID, uuid.UUID, error) {
+	claims, err := ks.ParseAccessJWT(tokenString)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return claims.UserID, claims.SessionID, nil
+}
+
+// ParseAccessJWT checks tokenString and returns what it claims
+func (ks *KeySet) ParseAccessJWT(tokenString string) (AccessClaims, error) {
+	claims := &sessionClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		// the algorithm is fixed by the key, never by the token
+		if t.Method.Alg() != ks.methods[kid].Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", t.Method.Alg(), kid)
+		}
+		return key.Public, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(ks.Issuer),
+		jwt.WithAudience(ks.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return AccessClaims{}, err
+	}
+	return parseSessionClaims(claims)
+}
+
+// JWK is a public key in JSON Web Key form
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists every key tokens may be verified with, in kid order
+func (ks *KeySet) JWKS() JWKS {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := JWKS{Keys: []JWK{}}
+	b64 := base64.RawURLEncoding
+	for _, id := range ids {
+		jwk := JWK{Kid: id, Use: "sig", Alg: ks.methods[id].Alg()}
+		switch pub := ks.keys[id].Public.(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", b64.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64.EncodeToString(pub.N.Bytes())
+			jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// ParseKeyPEM reads a PEM encoded Ed25519 or RSA key, either a private key
+// (PKCS#8 or PKCS#1) or a public key (PKIX)
+func ParseKeyPEM(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("key %s: no PEM data", id)
+	}
+	key := Key{ID: id}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return Key{}, fmt.Errorf("key %s: unsupported private key type %T", id, parsed)
+		}
+		key.Private = signer
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		key.Private = parsed
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("key %s: %w", id, err)
+		}
+		key.Public = parsed
+	default:
+		return Key{}, fmt.Errorf("key %s: unexpected PEM block %q", id, block.Type)
+	}
+	if key.Private != nil {
+		key.Public = key.Private.Public()
+	}
+	if _, err := key.Method(); err != nil {
+		return Key{}, err
+	}
+	return key, nil
+}
+
+// LoadKeySetDir builds a KeySet from every <kid>.pem file in dir. The file
+// named activeID signs new tokens; the rest are only used to verify, so a
+// rotated-out key can stay until its last token has expired.
+func LoadKeySetDir(dir, activeID, issuer, audience string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	var signing *Key
+	var verifyOnly []Key
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKeyPEM(strings.TrimSuffix(filepath.Base(path), ".pem"), data)
+		if err != nil {
+			return nil, err
+		}
+		if key.ID == activeID {
+			signing = &key
+			continue
+		}
+		verifyOnly = append(verifyOnly, key)
+	}
+	if signing == nil {
+		return nil, fmt.Errorf("no key file %s.pem in %s", activeID, dir)
+	}
+	return NewKeySet(issuer, audience, *signing, verifyOnly...)
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEd25519Key(t *testing.T, id string) Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return Key{ID: id, Private: priv, Public: priv.Public()}
+}
+
+func newRSAKey(t *testing.T, id string, bits int) Key {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	return Key{ID: id, Private: priv, Public: priv.Public()}
+}
+
+func TestKeySetRoundTrip(t *testing.T) {
+	for _, key := range []Key{newEd25519Key(t, "ed"), newRSAKey(t, "rsa", 2048)} {
+		ks, err := NewKeySet("chirpy", "chirpy-api", key)
+		require.NoError(t, err)
+
+		userID, sessionID := uuid.New(), uuid.New()
+		token, err := ks.MakeJWT(userID, sessionID, time.Hour)
+		require.NoError(t, err)
+
+		gotUser, gotSession, err := ks.ValidateJWT(token)
+		require.NoError(t, err, key.ID)
+		assert.Equal(t, userID, gotUser)
+		assert.Equal(t, sessionID, gotSession)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey, newKey := newEd25519Key(t, "2025-01"), newEd25519Key(t, "2025-07")
+
+	before, err := NewKeySet("chirpy", "chirpy-api", oldKey)
+	require.NoError(t, err)
+	token, err := before.MakeJWT(uuid.New(), uuid.Nil, time.Hour)
+	require.NoError(t, err)
+
+	// the old key signs no more but still verifies
+	after, err := NewKeySet("chirpy", "chirpy-api", newKey, Key{ID: oldKey.ID, Public: oldKey.Public})
+	require.NoError(t, err)
+	_, _, err = after.ValidateJWT(token)
+	assert.NoError(t, err)
+
+	// once dropped, its tokens stop working
+	dropped, err := NewKeySet("chirpy", "chirpy-api", newKey)
+	require.NoError(t, err)
+	_, _, err = dropped.ValidateJWT(token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestKeySetRejects(t *testing.T) {
+	key := newRSAKey(t, "rsa", 2048)
+	ks, err := NewKeySet("chirpy", "chirpy-api", key)
+	require.NoError(t, err)
+
+	sign := func(method jwt.SigningMethod, signingKey interface{}, claims jwt.RegisteredClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = "rsa"
+		signed, err := token.SignedString(signingKey)
+		require.NoError(t, err)
+		return signed
+	}
+	valid := jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Audience:  jwt.ClaimStrings{"chirpy-api"},
+		Subject:   uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	// HS256 keyed with the public key: the classic algorithm confusion
+	pubDER, err := x509.MarshalPKIXPublicKey(key.Public)
+	require.NoError(t, err)
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	_, _, err = ks.ValidateJWT(sign(jwt.SigningMethodHS256, pubPEM, valid))
+	assert.Error(t, err)
+
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "someone-else"
+	_, _, err = ks.ValidateJWT(sign(jwt.SigningMethodRS256, key.Private, wrongIssuer))
+	assert.Error(t, err)
+
+	wrongAudience := valid
+	wrongAudience.Audience = jwt.ClaimStrings{"other-api"}
+	_, _, err = ks.ValidateJWT(sign(jwt.SigningMethodRS256, key.Private, wrongAudience))
+	assert.Error(t, err)
+
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+	_, _, err = ks.ValidateJWT(sign(jwt.SigningMethodRS256, key.Private, noExpiry))
+	assert.Error(t, err)
+
+	_, err = NewKeySet("chirpy", "chirpy-api", newRSAKey(t, "small", 1024))
+	assert.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	ed := newEd25519Key(t, "a")
+	rsaKey := newRSAKey(t, "b", 2048)
+	ks, err := NewKeySet("chirpy", "chirpy-api", ed, Key{ID: rsaKey.ID, Public: rsaKey.Public})
+	require.NoError(t, err)
+
+	set := ks.JWKS()
+	require.Len(t, set.Keys, 2)
+	assert.Equal(t, JWK{
+		Kty: "OKP", Kid: "a", Use: "sig", Alg: "EdDSA", Crv: "Ed25519",
+		X: base64.RawURLEncoding.EncodeToString(ed.Public.(ed25519.PublicKey)),
+	}, set.Keys[0])
+	assert.Equal(t, "RSA", set.Keys[1].Kty)
+	assert.Equal(t, "RS256", set.Keys[1].Alg)
+	assert.Equal(t, "AQAB", set.Keys[1].E)
+}
+
+func TestLoadKeySetDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, block *pem.Block) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600))
+	}
+	active := newEd25519Key(t, "active")
+	der, err := x509.MarshalPKCS8PrivateKey(active.Private)
+	require.NoError(t, err)
+	write("active.pem", &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	old := newRSAKey(t, "old", 2048)
+	pubDER, err := x509.MarshalPKIXPublicKey(old.Public)
+	require.NoError(t, err)
+	write("old.pem", &pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+	ks, err := LoadKeySetDir(dir, "active", "chirpy", "chirpy-api")
+	require.NoError(t, err)
+	assert.Len(t, ks.JWKS().Keys, 2)
+
+	_, err = LoadKeySetDir(dir, "missing", "chirpy", "chirpy-api")
+	assert.Error(t, err)
+	// a public key can't be the signing key
+	_, err = LoadKeySetDir(dir, "old", "chirpy", "chirpy-api")
+	assert.Error(t, err)
+}
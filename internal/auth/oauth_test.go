@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// the example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	assert.Equal(t, challenge, PKCEChallenge(verifier))
+	assert.True(t, VerifyPKCE(verifier, challenge))
+	assert.False(t, VerifyPKCE(verifier+"x", challenge))
+	// a too short verifier is refused even when it matches
+	assert.False(t, VerifyPKCE("short", PKCEChallenge("short")))
+	assert.False(t, ValidCodeVerifier(strings.Repeat("a", 129)))
+	assert.False(t, ValidCodeVerifier(strings.Repeat("a", 42)+"/"))
+}
+
+func TestScopeParam(t *testing.T) {
+	assert.Equal(t, []string{ScopeChirpsRead, ScopeChirpsWrite}, ParseScope(" chirps:read  chirps:write "))
+	assert.Equal(t, "chirps:read chirps:write", FormatScope([]string{ScopeChirpsRead, ScopeChirpsWrite}))
+	assert.Empty(t, ParseScope(""))
+}
+
+func TestAccessJWTClaims(t *testing.T) {
+	userID, grantID := uuid.New(), uuid.New()
+	token, err := MakeAccessJWT(AccessClaims{
+		UserID:    userID,
+		SessionID: grantID,
+		ClientID:  "client",
+		Scopes:    []string{ScopeChirpsRead},
+	}, "secret", time.Minute)
+	require.NoError(t, err)
+
+	claims, err := ParseAccessJWT(token, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, grantID, claims.SessionID)
+	assert.Equal(t, "client", claims.ClientID)
+	assert.Equal(t, []string{ScopeChirpsRead}, claims.Scopes)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), claims.ExpiresAt, 2*time.Second)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scopes a personal access token can be granted. Access tokens from
+// logging in carry every scope.
+const (
+	ScopeChirpsRead     = "chirps:read"
+	ScopeChirpsWrite    = "chirps:write"
+	ScopeMediaWrite     = "media:write"
+	ScopeFollowsWrite   = "follows:write"
+	ScopeReactionsWrite = "reactions:write"
+	// ScopeProfileWrite doesn't cover changing the email or password,
+	// which only an access token from logging in can do
+	ScopeProfileWrite = "profile:write"
+)
+
+// Scopes lists every valid scope
+var Scopes = []string{
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeMediaWrite,
+	ScopeFollowsWrite,
+	ScopeReactionsWrite,
+	ScopeProfileWrite,
+}
+
+// personalAccessTokenPrefix marks personal access tokens, so they can be
+// told apart from JWTs and spotted by secret scanners
+const personalAccessTokenPrefix = "chirpy_pat_"
+
+// MakePersonalAccessToken returns a new random personal access token
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return personalAccessTokenPrefix + token, nil
+}
+
+// IsPersonalAccessToken reports whether a bearer token is a personal
+// access token rather than a JWT
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
+
+// ValidateScopes checks every requested scope exists and returns them
+// without duplicates
+func ValidateScopes(requested []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, scope := range requested {
+		if !HasScope(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			out = append(out, scope)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return out, nil
+}
+
+// HasScope reports whether granted includes scope
+func HasScope(granted []string, scope string) bool {
+	for _, g := range granted {
+		if g == scope {
+			return true
+		}
+	}
+	return false
+}
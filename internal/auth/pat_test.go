@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	require.NoError(t, err)
+	assert.True(t, IsPersonalAccessToken(token))
+
+	jwt, err := MakeJWT([16]byte{1}, "secret", 60)
+	require.NoError(t, err)
+	assert.False(t, IsPersonalAccessToken(jwt))
+}
+
+func TestValidateScopes(t *testing.T) {
+	scopes, err := ValidateScopes([]string{ScopeChirpsRead, ScopeChirpsWrite, ScopeChirpsRead})
+	require.NoError(t, err)
+	assert.Equal(t, []string{ScopeChirpsRead, ScopeChirpsWrite}, scopes)
+
+	_, err = ValidateScopes([]string{"admin:everything"})
+	assert.Error(t, err)
+	_, err = ValidateScopes(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,32 @@
+package auth
+
+// Roles a user can have, each allowed everything the ones before it are
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists every role, least privileged first
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+func roleRank(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidRole reports whether role exists
+func ValidRole(role string) bool {
+	return roleRank(role) >= 0
+}
+
+// RoleAtLeast reports whether role is min or a more privileged one. An
+// unknown role is never enough.
+func RoleAtLeast(role, min string) bool {
+	rank := roleRank(role)
+	return rank >= 0 && rank >= roleRank(min)
+}
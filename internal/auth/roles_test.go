@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleAtLeast(t *testing.T) {
+	assert.True(t, RoleAtLeast(RoleAdmin, RoleModerator))
+	assert.True(t, RoleAtLeast(RoleModerator, RoleModerator))
+	assert.False(t, RoleAtLeast(RoleUser, RoleModerator))
+	assert.False(t, RoleAtLeast("", RoleUser))
+	assert.False(t, RoleAtLeast("superuser", RoleUser))
+	assert.True(t, ValidRole(RoleModerator))
+	assert.False(t, ValidRole("root"))
+}
+
+func TestAccessJWTRole(t *testing.T) {
+	token, err := MakeAccessJWT(AccessClaims{UserID: uuid.New(), Role: RoleModerator}, "secret", time.Minute)
+	require.NoError(t, err)
+	claims, err := ParseAccessJWT(token, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, RoleModerator, claims.Role)
+}
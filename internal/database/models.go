@@ -12,27 +12,255 @@ import (
 )
 
 type Chirp struct {
-	ID        uuid.UUID
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.NullUUID
+	Body       string
+	BodyTsv    interface{}
+	ParentID   uuid.NullUUID
+	RootID     uuid.NullUUID
+	DeletedAt  sql.NullTime
+	ReplyCount int32
+	Kind       string
+	OriginalID uuid.NullUUID
+	EditedAt   sql.NullTime
+}
+
+type ChirpAttachment struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	SizeBytes    int32
+	Width        int32
+	Height       int32
+	StorageKey   string
+	ThumbnailKey sql.NullString
+	CreatedAt    time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	HashtagID uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type ChirpReaction struct {
 	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	Reaction  string
+	CreatedAt time.Time
+}
+
+type ChirpRevision struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
 	Body      string
+	CreatedAt time.Time
 }
 
-type RefreshToken struct {
-	Token     string
+type EmailVerification struct {
+	ID        uuid.UUID
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type ExternalIdentity struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Issuer      string
+	Subject     string
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Hashtag struct {
+	ID        uuid.UUID
+	Tag       string
+	CreatedAt time.Time
+}
+
+type LoginFailure struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type MagicLink struct {
+	ID        uuid.UUID
+	TokenHash string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type MfaChallenge struct {
+	ID               uuid.UUID
+	TokenHash        string
+	UserID           uuid.UUID
+	ExpiresInSeconds int32
+	Attempts         int32
+	ExpiresAt        time.Time
+	UsedAt           sql.NullTime
+	CreatedAt        time.Time
+}
+
+type ModerationFlag struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	RuleKind   string
+	Pattern    string
+	Matched    string
+	CreatedAt  time.Time
+	ResolvedAt sql.NullTime
+}
+
+type ModerationRule struct {
+	ID        uuid.UUID
+	Kind      string
+	Pattern   string
+	Action    string
+	CreatedAt time.Time
+}
+
+type OauthAuthorizationCode struct {
+	ID            uuid.UUID
+	CodeHash      string
+	GrantID       uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+	CreatedAt     time.Time
+}
+
+type OauthClient struct {
+	ID               uuid.UUID
+	ClientID         string
+	ClientSecretHash sql.NullString
+	Name             string
+	RedirectUris     []string
+	OwnerID          uuid.UUID
+	CreatedAt        time.Time
+	RevokedAt        sql.NullTime
+}
+
+type OauthGrant struct {
+	ID        uuid.UUID
 	UserID    uuid.UUID
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
+	ClientID  uuid.UUID
+	Scopes    []string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	RevokedAt sql.NullTime
+}
+
+type OauthRefreshToken struct {
+	ID        uuid.UUID
+	TokenHash string
+	GrantID   uuid.UUID
+	Scopes    []string
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	CreatedAt time.Time
+}
+
+type OidcLoginState struct {
+	ID           uuid.UUID
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+type PasswordReset struct {
+	ID        uuid.UUID
+	TokenHash string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type RefreshToken struct {
+	TokenHash   string
+	UserID      uuid.UUID
+	CreatedAt   sql.NullTime
+	UpdatedAt   sql.NullTime
+	ExpiresAt   time.Time
+	RevokedAt   sql.NullTime
+	FamilyID    uuid.UUID
+	ReplacedBy  sql.NullString
+	HashVersion int32
+}
+
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	UserAgent  string
+	Ip         string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
 }
 
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Email            string
+	HashedPassword   string
+	IsChirpyRed      bool
+	EmailVerifiedAt  sql.NullTime
+	Role             string
+	SuspendedAt      sql.NullTime
+	SuspendedUntil   sql.NullTime
+	SuspensionReason sql.NullString
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	Secret       string
+	ConfirmedAt  sql.NullTime
+	LastUsedStep sql.NullInt64
+	CreatedAt    time.Time
 }
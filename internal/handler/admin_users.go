@@ -0,0 +1,401 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxSuspensionReason bounds the reason shown to a suspended user
+const maxSuspensionReason = 500
+
+var errAccountSuspended = errors.New("account suspended")
+
+type suspensionResponse struct {
+	SuspendedAt time.Time  `json:"suspended_at"`
+	Until       *time.Time `json:"until"`
+	Reason      string     `json:"reason"`
+}
+
+type adminUserResponse struct {
+	ID            uuid.UUID           `json:"id"`
+	Email         string              `json:"email"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+	Role          string              `json:"role"`
+	IsChirpyRed   bool                `json:"is_chirpy_red"`
+	EmailVerified bool                `json:"email_verified"`
+	Suspension    *suspensionResponse `json:"suspension"`
+}
+
+func newAdminUserResponse(user database.User) adminUserResponse {
+	resp := adminUserResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Role:          user.Role,
+		IsChirpyRed:   user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	}
+	if suspended(user, time.Now()) {
+		resp.Suspension = newSuspensionResponse(user)
+	}
+	return resp
+}
+
+func newSuspensionResponse(user database.User) *suspensionResponse {
+	s := &suspensionResponse{SuspendedAt: user.SuspendedAt.Time, Reason: user.SuspensionReason.String}
+	if user.SuspendedUntil.Valid {
+		s.Until = &user.SuspendedUntil.Time
+	}
+	return s
+}
+
+// suspended reports whether user's suspension is in force at now. One
+// that has run out needs no clearing up; it just stops applying.
+func suspended(user database.User, now time.Time) bool {
+	return user.SuspendedAt.Valid && (!user.SuspendedUntil.Valid || now.Before(user.SuspendedUntil.Time))
+}
+
+// respondSuspended tells a suspended user why they can't go on and until when
+func respondSuspended(w http.ResponseWriter, user database.User) {
+	type respBody struct {
+		Error      string              `json:"error"`
+		Suspension *suspensionResponse `json:"suspension"`
+	}
+	respondWithJSON(w, http.StatusForbidden, respBody{Error: "account suspended", Suspension: newSuspensionResponse(user)})
+}
+
+// checkNotSuspended returns errAccountSuspended when userId is suspended
+func (cfg *ApiConfig) checkNotSuspended(ctx context.Context, userId uuid.UUID) error {
+	user, err := cfg.DB.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if suspended(user, time.Now()) {
+		return errAccountSuspended
+	}
+	return nil
+}
+
+// revokeAllAccess logs userId out everywhere: every session, personal
+// access token and app they have authorized stops working
+func revokeAllAccess(ctx context.Context, q *database.Queries, userId uuid.UUID) error {
+	if err := revokeOtherSessions(ctx, q, userId, uuid.Nil); err != nil {
+		return err
+	}
+	if err := q.RevokePersonalAccessTokensForUser(ctx, userId); err != nil {
+		return err
+	}
+	if err := q.RevokeOAuthRefreshTokensForUser(ctx, userId); err != nil {
+		return err
+	}
+	return q.RevokeOAuthGrantsForUser(ctx, userId)
+}
+
+// escapeLike makes s match only itself in a LIKE pattern
+var escapeLike = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace
+
+// GetAdminUsers handles GET /admin/users, listing users oldest first. q
+// narrows the list to emails containing it; limit and cursor page through
+// it as they do for chirps.
+func (cfg *ApiConfig) GetAdminUsers(w http.ResponseWriter, r *http.Request) {
+	limit, err := parsePageLimit(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	params := database.ListUsersParams{Limit: limit + 1}
+	if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+		params.Query = sql.NullString{String: escapeLike(q), Valid: true}
+	}
+	if rawCursor := r.URL.Query().Get("cursor"); rawCursor != "" {
+		cursor, err := decodeCursor(rawCursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	users, err := cfg.DB.ListUsers(r.Context(), params)
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting users")
+		return
+	}
+	nextCursor := ""
+	if len(users) > int(limit) {
+		users = users[:limit]
+		last := users[len(users)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	resp := make([]adminUserResponse, 0, len(users))
+	for _, user := range users {
+		resp = append(resp, newAdminUserResponse(user))
+	}
+	setNextPage(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// adminUser loads the user named by the request's {id}, writing the error
+// response and returning false when it can't
+func (cfg *ApiConfig) adminUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return database.User{}, false
+	}
+	user, err := cfg.DB.GetUserByID(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return database.User{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting user")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// GetAdminUser handles GET /admin/users/{id} with what an admin needs to
+// look into an account: its chirp count, active sessions, Red status and
+// any suspension
+func (cfg *ApiConfig) GetAdminUser(w http.ResponseWriter, r *http.Request) {
+	type respBody struct {
+		adminUserResponse
+		MFAEnabled bool              `json:"mfa_enabled"`
+		ChirpCount int64             `json:"chirp_count"`
+		Sessions   []sessionResponse `json:"sessions"`
+	}
+	user, ok := cfg.adminUser(w, r)
+	if !ok {
+		return
+	}
+	chirpCount, err := cfg.DB.CountChirpsByUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting user")
+		return
+	}
+	mfa, err := cfg.mfaEnabled(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting user")
+		return
+	}
+	sessions, err := cfg.DB.ListActiveSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting user")
+		return
+	}
+	resp := respBody{
+		adminUserResponse: newAdminUserResponse(user),
+		MFAEnabled:        mfa,
+		ChirpCount:        chirpCount,
+		Sessions:          make([]sessionResponse, 0, len(sessions)),
+	}
+	for _, s := range sessions {
+		resp.Sessions = append(resp.Sessions, sessionResponse{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.Ip,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// SuspendUser handles PUT /admin/users/{id}/suspension. The body gives the
+// reason, which the user is shown, and optionally when the suspension
+// ends. The user is logged out everywhere, as LogoutUser does, and no
+// token of theirs is accepted until it ends or is lifted. Admins can't be suspended; demote them
+// first.
+func (cfg *ApiConfig) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Reason string     `json:"reason"`
+		Until  *time.Time `json:"until"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" || len(params.Reason) > maxSuspensionReason {
+		respondWithError(w, http.StatusBadRequest, "reason is required and must be at most 500 characters")
+		return
+	}
+	until := sql.NullTime{}
+	if params.Until != nil {
+		if !params.Until.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "until must be in the future")
+			return
+		}
+		until = sql.NullTime{Time: params.Until.UTC(), Valid: true}
+	}
+	user, ok := cfg.adminUser(w, r)
+	if !ok {
+		return
+	}
+	if user.ID == staffUserID(r) {
+		respondWithError(w, http.StatusForbidden, "you can't suspend yourself")
+		return
+	}
+	if auth.RoleAtLeast(user.Role, auth.RoleAdmin) {
+		respondWithError(w, http.StatusForbidden, "admins can't be suspended")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to suspend user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+	user, err = qtx.SuspendUser(r.Context(), database.SuspendUserParams{
+		ID:               user.ID,
+		SuspendedUntil:   until,
+		SuspensionReason: sql.NullString{String: params.Reason, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error suspending user: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to suspend user")
+		return
+	}
+	if err := revokeAllAccess(r.Context(), qtx, user.ID); err != nil {
+		log.Printf("Error revoking access: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to suspend user")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to suspend user")
+		return
+	}
+	log.Printf("User %s suspended %s: %s", staffUserID(r), user.ID, params.Reason)
+	respondWithJSON(w, http.StatusOK, newAdminUserResponse(user))
+}
+
+// UnsuspendUser handles DELETE /admin/users/{id}/suspension, lifting a
+// suspension early
+func (cfg *ApiConfig) UnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminUser(w, r)
+	if !ok {
+		return
+	}
+	user, err := cfg.DB.UnsuspendUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error lifting suspension: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to lift suspension")
+		return
+	}
+	log.Printf("User %s lifted the suspension of %s", staffUserID(r), user.ID)
+	respondWithJSON(w, http.StatusOK, newAdminUserResponse(user))
+}
+
+// LogoutUser handles POST /admin/users/{id}/logout, ending every session
+// the user has and revoking their personal access tokens and authorized
+// apps. As with DeleteSession, access tokens already issued from logging
+// in run out on their own within the hour.
+func (cfg *ApiConfig) LogoutUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminUser(w, r)
+	if !ok {
+		return
+	}
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	defer tx.Rollback()
+	if err := revokeAllAccess(r.Context(), cfg.DB.WithTx(tx), user.ID); err != nil {
+		log.Printf("Error revoking access: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteAdminUser handles DELETE /admin/users/{id}, removing one account
+// and everything it owns
+func (cfg *ApiConfig) DeleteAdminUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminUser(w, r)
+	if !ok {
+		return
+	}
+	if user.ID == staffUserID(r) {
+		respondWithError(w, http.StatusForbidden, "you can't delete yourself")
+		return
+	}
+	if err := cfg.deleteUser(r.Context(), user.ID); err != nil {
+		log.Printf("Error deleting user: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+	log.Printf("User %s deleted %s (%s)", staffUserID(r), user.ID, user.Email)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteUser deletes userId. Their chirps go one by one as deleteChirp
+// removes them, so those with replies stay as tombstones, which outlive
+// the user without an author. Most of what else the user owns goes with
+// the row.
+func (cfg *ApiConfig) deleteUser(ctx context.Context, userId uuid.UUID) error {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	// newest first, so replies within the user's own threads are gone
+	// before the chirps they answer are looked at
+	chirpIds, err := qtx.GetChirpIDsByUserNewestFirst(ctx, userId)
+	if err != nil {
+		return err
+	}
+	var attachments []database.DeleteChirpAttachmentsRow
+	for _, chirpId := range chirpIds {
+		removed, err := removeChirp(ctx, qtx, chirpId)
+		if err != nil {
+			return err
+		}
+		attachments = append(attachments, removed...)
+	}
+	// what is left are uploads never attached to a chirp
+	unattached, err := qtx.DeleteUserAttachments(ctx, userId)
+	if err != nil {
+		return err
+	}
+	for _, a := range unattached {
+		attachments = append(attachments, database.DeleteChirpAttachmentsRow(a))
+	}
+	if _, err := qtx.DeleteUser(ctx, userId); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// files go only once the rows are gone for good
+	for _, a := range attachments {
+		cfg.deleteStoredMedia(ctx, a.StorageKey, a.ThumbnailKey.String)
+	}
+	return nil
+}
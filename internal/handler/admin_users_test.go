@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuspended(t *testing.T) {
+	now := time.Now()
+	user := database.User{}
+	assert.False(t, suspended(user, now))
+
+	user.SuspendedAt = sql.NullTime{Time: now.Add(-time.Hour), Valid: true}
+	assert.True(t, suspended(user, now), "no end date")
+
+	user.SuspendedUntil = sql.NullTime{Time: now.Add(time.Hour), Valid: true}
+	assert.True(t, suspended(user, now))
+	assert.False(t, suspended(user, now.Add(2*time.Hour)), "ran out")
+
+	// an expired suspension isn't reported
+	user.SuspensionReason = sql.NullString{String: "spam", Valid: true}
+	assert.NotNil(t, newAdminUserResponse(user).Suspension)
+	user.SuspendedUntil.Time = now.Add(-time.Minute)
+	assert.Nil(t, newAdminUserResponse(user).Suspension)
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `100\%\_off\\`, escapeLike(`100%_off\`))
+	assert.Equal(t, "walt@example.com", escapeLike("walt@example.com"))
+}
+
+func TestSuspendUserValidates(t *testing.T) {
+	cfg := &ApiConfig{}
+	for name, body := range map[string]string{
+		"not json":    `{`,
+		"no reason":   `{"reason": "  "}`,
+		"long reason": `{"reason": "` + strings.Repeat("x", maxSuspensionReason+1) + `"}`,
+		"in the past": `{"reason": "spam", "until": "2020-01-01T00:00:00Z"}`,
+	} {
+		r := httptest.NewRequest(http.MethodPut, "/admin/users/x/suspension", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		cfg.SuspendUser(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+	}
+}
+
+func TestRespondWithAuthErrorSuspended(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondWithAuthError(w, errAccountSuspended)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "suspended")
+}
+
+func TestDeleteUserKeepsOthersReplies(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	walt, jesse := uuid.New(), uuid.New()
+	chirps := map[string]*database.Chirp{}
+	var order []string
+	post := func(userId uuid.UUID, parent *database.Chirp) *database.Chirp {
+		chirp := &database.Chirp{
+			ID: uuid.New(), CreatedAt: fakeNow.Add(time.Duration(len(order)) * time.Minute), UpdatedAt: fakeNow,
+			UserID: uuid.NullUUID{UUID: userId, Valid: true}, Body: "chirp", Kind: chirpKindChirp,
+		}
+		if parent != nil {
+			chirp.ParentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+			parent.ReplyCount++
+		}
+		chirps[chirp.ID.String()] = chirp
+		order = append(order, chirp.ID.String())
+		return chirp
+	}
+	answered := post(walt, nil)
+	reply := post(jesse, answered)
+	selfThread := post(walt, nil)
+	selfReply := post(walt, selfThread)
+	lone := post(walt, nil)
+	jesses := post(jesse, nil)
+	waltsReply := post(walt, jesses)
+
+	// the chirps table, as removeChirp's queries change it
+	db.onFunc("GetChirpIDsByUserNewestFirst", func(args []driver.Value) ([][]driver.Value, error) {
+		var rows [][]driver.Value
+		for i := len(order) - 1; i >= 0; i-- {
+			if chirp, ok := chirps[order[i]]; ok && chirp.UserID.UUID.String() == args[0] {
+				rows = append(rows, []driver.Value{chirp.ID.String()})
+			}
+		}
+		return rows, nil
+	})
+	db.onFunc("GetChirpForUpdate", func(args []driver.Value) ([][]driver.Value, error) {
+		return [][]driver.Value{rowValues(*chirps[args[0].(string)])}, nil
+	})
+	db.onFunc("TombstoneChirp", func(args []driver.Value) ([][]driver.Value, error) {
+		chirp := chirps[args[0].(string)]
+		chirp.Body, chirp.DeletedAt = "", sql.NullTime{Time: fakeNow, Valid: true}
+		return nil, nil
+	})
+	db.onFunc("DeleteChirp", func(args []driver.Value) ([][]driver.Value, error) {
+		delete(chirps, args[0].(string))
+		return nil, nil
+	})
+	db.onFunc("DecrementReplyCount", func(args []driver.Value) ([][]driver.Value, error) {
+		chirps[args[0].(string)].ReplyCount--
+		return nil, nil
+	})
+
+	require.NoError(t, cfg.deleteUser(context.Background(), walt))
+	assert.Len(t, db.called("DeleteUser"), 1)
+
+	// jesse's reply keeps the chirp it answers, as a tombstone
+	require.Contains(t, chirps, answered.ID.String())
+	assert.True(t, answered.DeletedAt.Valid)
+	assert.Empty(t, answered.Body)
+	assert.Equal(t, int32(1), answered.ReplyCount)
+	assert.Contains(t, chirps, reply.ID.String())
+
+	// a thread of walt's own goes whole, reply first
+	for _, chirp := range []*database.Chirp{selfThread, selfReply, lone, waltsReply} {
+		assert.NotContains(t, chirps, chirp.ID.String())
+	}
+	assert.Equal(t, int32(0), jesses.ReplyCount)
+	assert.Len(t, db.called("TombstoneChirp"), 1)
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+// maxChirpAttachments is how many images one chirp can carry
+const maxChirpAttachments = 4
+
+// attachmentResponse is the JSON shape of an uploaded image
+type attachmentResponse struct {
+	ID           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	SizeBytes    int32     `json:"size_bytes"`
+}
+
+func (cfg *ApiConfig) newAttachmentResponse(a database.ChirpAttachment) attachmentResponse {
+	resp := attachmentResponse{
+		ID:          a.ID,
+		ContentType: a.ContentType,
+		Width:       a.Width,
+		Height:      a.Height,
+		SizeBytes:   a.SizeBytes,
+	}
+	if cfg.Media != nil {
+		resp.URL = cfg.Media.URL(a.StorageKey)
+		if a.ThumbnailKey.Valid {
+			resp.ThumbnailURL = cfg.Media.URL(a.ThumbnailKey.String)
+		}
+	}
+	return resp
+}
+
+// UploadMedia handles POST /api/media. The image goes in the multipart
+// field "file"; the returned id can then be listed in a new chirp's
+// attachments. The stored file never has the client's metadata or name.
+func (cfg *ApiConfig) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeMediaWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	if cfg.Media == nil {
+		respondWithError(w, http.StatusServiceUnavailable, "media uploads are not configured")
+		return
+	}
+	if !cfg.requireVerifiedEmail(w, r, userId, restrictMedia) {
+		return
+	}
+
+	// leave room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadSize+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, media.ErrTooLarge.Error())
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "expected an image in the multipart field \"file\"")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadSize+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "error reading upload")
+		return
+	}
+	img, err := media.Process(data)
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case errors.Is(err, media.ErrUnsupportedType):
+		respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	case err != nil:
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := uuid.New().String()
+	key := name + media.Extension(img.ContentType)
+	if err := cfg.Media.Put(r.Context(), key, img.ContentType, img.Data); err != nil {
+		log.Printf("Error storing media: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error storing image")
+		return
+	}
+	var thumbKey sql.NullString
+	if img.Thumbnail != nil {
+		thumbKey = sql.NullString{String: fmt.Sprintf("%s-thumb%s", name, media.Extension(img.ThumbnailType)), Valid: true}
+		if err := cfg.Media.Put(r.Context(), thumbKey.String, img.ThumbnailType, img.Thumbnail); err != nil {
+			log.Printf("Error storing thumbnail: %v", err)
+			cfg.deleteStoredMedia(r.Context(), key)
+			respondWithError(w, http.StatusInternalServerError, "error storing image")
+			return
+		}
+	}
+
+	attachment, err := cfg.DB.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+		UserID:       userId,
+		ContentType:  img.ContentType,
+		SizeBytes:    int32(len(img.Data)),
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		StorageKey:   key,
+		ThumbnailKey: thumbKey,
+	})
+	if err != nil {
+		log.Printf("Error saving attachment: %v", err)
+		cfg.deleteStoredMedia(r.Context(), key, thumbKey.String)
+		respondWithError(w, http.StatusInternalServerError, "error storing image")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, cfg.newAttachmentResponse(attachment))
+}
+
+// saveChirpAttachments attaches the caller's uploads to chirp in the given
+// order. It fails if any id isn't an unused upload belonging to userId.
+func saveChirpAttachments(ctx context.Context, q *database.Queries, chirpId, userId uuid.UUID, ids []uuid.UUID) error {
+	for i, id := range ids {
+		attached, err := q.AttachToChirp(ctx, database.AttachToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirpId, Valid: true},
+			Position: int32(i),
+			ID:       id,
+			UserID:   userId,
+		})
+		if err != nil {
+			return err
+		}
+		if attached == 0 {
+			return errAttachmentNotFound
+		}
+	}
+	return nil
+}
+
+var errAttachmentNotFound = errors.New("attachment not found or already used")
+
+// validateAttachmentIDs checks the attachment list of a new chirp
+func validateAttachmentIDs(ids []uuid.UUID) error {
+	if len(ids) > maxChirpAttachments {
+		return fmt.Errorf("a chirp can have at most %d attachments", maxChirpAttachments)
+	}
+	seen := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			return errors.New("attachments must not repeat")
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// deleteStoredMedia removes files whose rows are already gone; failures
+// only leave orphaned files behind, so they are logged and ignored
+func (cfg *ApiConfig) deleteStoredMedia(ctx context.Context, keys ...string) {
+	if cfg.Media == nil {
+		return
+	}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := cfg.Media.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting media %s: %v", key, err)
+		}
+	}
+}
+
+// attachMedia fills in Attachments on chirps and on any embedded originals
+func (cfg *ApiConfig) attachMedia(ctx context.Context, chirps []chirpResponse) error {
+	var targets []*chirpResponse
+	for i := range chirps {
+		targets = append(targets, &chirps[i])
+		if chirps[i].Original != nil {
+			targets = append(targets, chirps[i].Original)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(targets))
+	for _, chirp := range targets {
+		ids = append(ids, chirp.ID)
+	}
+
+	rows, err := cfg.DB.GetAttachmentsForChirps(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byChirp := map[uuid.UUID][]attachmentResponse{}
+	for _, row := range rows {
+		byChirp[row.ChirpID.UUID] = append(byChirp[row.ChirpID.UUID], cfg.newAttachmentResponse(row))
+	}
+	for _, chirp := range targets {
+		chirp.Attachments = byChirp[chirp.ID]
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,24 +16,23 @@ import (
 )
 
 type parameters struct {
-	Body   string    `json:"body"`
-	UserId uuid.UUID `json:"user_id"`
+	Body      string     `json:"body"`
+	UserId    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	QuoteOf   *uuid.UUID `json:"quote_of"`
+	// Attachments are ids returned by POST /api/media
+	Attachments []uuid.UUID `json:"attachments"`
 }
 
 func (cfg *ApiConfig) CreateChirps(w http.ResponseWriter, r *http.Request) {
 	// Set JSON content type header
 	w.Header().Set("Content-Type", "application/json")
-	bearer_token, err := auth.GetBearerToken(r.Header)
-	fmt.Printf("Token Found: %v\n", bearer_token)
+	userID, err := cfg.authenticatedUserID(r, auth.ScopeChirpsWrite)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid token in request"))
+		respondWithAuthError(w, err)
 		return
 	}
-	userID, err := auth.ValidateJWT(bearer_token, cfg.Secret)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid token"))
+	if !cfg.requireVerifiedEmail(w, r, userID, restrictChirp) {
 		return
 	}
 
@@ -40,14 +40,6 @@ func (cfg *ApiConfig) CreateChirps(w http.ResponseWriter, r *http.Request) {
 		Error string `json:"error"`
 	}
 
-	type successResponse struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserID    uuid.UUID `json:"user_id"`
-	}
-
 	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
 	err = decoder.Decode(&params)
@@ -65,97 +57,222 @@ func (cfg *ApiConfig) CreateChirps(w http.ResponseWriter, r *http.Request) {
 		w.Write(errData)
 		return
 	}
-	data, err := validateChirp(params)
+	moderated, err := cfg.moderateChirp(params.Body)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		//log.Printf("%v\n",err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateAttachmentIDs(params.Attachments); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	chirpParams := database.CreateChirpParams{
-		Body:   data,
-		UserID: userID,
+		Body:   moderated.Body,
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Kind:   chirpKindChirp,
 	}
 
-	createdChirp, err := cfg.DB.CreateChirp(r.Context(), chirpParams)
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
 	if err != nil {
-		fmt.Printf("Error Creating Chirp %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "error creating chirp")
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if params.InReplyTo != nil {
+		parent, err := qtx.GetChirpForUpdate(r.Context(), *params.InReplyTo)
+		if err == nil && parent.Kind == chirpKindRechirp && parent.OriginalID.Valid {
+			// replying to a rechirp joins the original conversation
+			parent, err = qtx.GetChirpForUpdate(r.Context(), parent.OriginalID.UUID)
+		}
+		if err != nil || parent.DeletedAt.Valid {
+			respondWithError(w, http.StatusNotFound, "chirp being replied to not found")
+			return
+		}
+		rootID := parent.ID
+		if parent.RootID.Valid {
+			rootID = parent.RootID.UUID
+		}
+		chirpParams.ParentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		chirpParams.RootID = uuid.NullUUID{UUID: rootID, Valid: true}
+	}
 
-	respBody := successResponse{
-		ID:        createdChirp.ID,
-		CreatedAt: createdChirp.CreatedAt,
-		UpdatedAt: createdChirp.UpdatedAt,
-		Body:      createdChirp.Body,
-		UserID:    createdChirp.UserID,
+	if params.QuoteOf != nil {
+		original, err := cfg.resolveOriginal(r.Context(), qtx, *params.QuoteOf)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "quoted chirp not found")
+			return
+		}
+		chirpParams.Kind = chirpKindQuote
+		chirpParams.OriginalID = uuid.NullUUID{UUID: original.ID, Valid: true}
 	}
 
-	successData, err := json.Marshal(respBody)
+	createdChirp, err := qtx.CreateChirp(r.Context(), chirpParams)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Printf("Error Creating Chirp %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "error creating chirp")
+		return
+	}
+	if createdChirp.ParentID.Valid {
+		if err := qtx.IncrementReplyCount(r.Context(), createdChirp.ParentID.UUID); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "error creating chirp")
+			return
+		}
+	}
+	if err := saveChirpHashtags(r.Context(), qtx, createdChirp); err != nil {
+		log.Printf("Error saving hashtags: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error creating chirp")
+		return
+	}
+	if err := saveModerationFlags(r.Context(), qtx, createdChirp.ID, moderated); err != nil {
+		log.Printf("Error saving moderation flags: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error creating chirp")
+		return
+	}
+	err = saveChirpAttachments(r.Context(), qtx, createdChirp.ID, userID, params.Attachments)
+	if errors.Is(err, errAttachmentNotFound) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error attaching media: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error creating chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "error creating chirp")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write(successData)
+	resp := []chirpResponse{newChirpResponse(createdChirp)}
+	if err := cfg.decorateChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, resp); err != nil {
+		log.Printf("Error loading quoted chirp: %v", err)
+	}
+	respondWithJSON(w, http.StatusCreated, resp[0])
 }
 
-func (cfg *ApiConfig) GetAllChirps(w http.ResponseWriter, r *http.Request) {
-	// Set JSON content type header
-	w.Header().Set("Content-Type", "application/json")
+// chirpResponse is the JSON shape of a chirp
+type chirpResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Body       string     `json:"body"`
+	// UserId is null on the tombstones a deleted user's chirps leave
+	UserId     *uuid.UUID `json:"user_id"`
+	InReplyTo  *uuid.UUID `json:"in_reply_to,omitempty"`
+	ReplyCount int32      `json:"reply_count"`
+	Edited     bool       `json:"edited"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	// filled in by attachReactions
+	LikeCount   int64            `json:"like_count"`
+	Reactions   map[string]int64 `json:"reactions,omitempty"`
+	LikedByMe   *bool            `json:"liked_by_me,omitempty"`
+	MyReactions []string         `json:"my_reactions,omitempty"`
+	// Kind is chirp, rechirp or quote; Original is filled in by attachOriginals
+	Kind                string         `json:"kind"`
+	Original            *chirpResponse `json:"original,omitempty"`
+	OriginalUnavailable bool           `json:"original_unavailable,omitempty"`
+	originalID          uuid.NullUUID
+	// filled in by attachMedia
+	Attachments []attachmentResponse `json:"attachments,omitempty"`
+}
 
-	authorID := r.URL.Query().Get("author_id")
-	fmt.Printf("authorId: %v\n",authorID)
-	if authorID != ""{
-		userId,err := uuid.Parse(authorID)
-		if err != nil{
-			respondWithError(w,http.StatusInternalServerError,"error occurred parsing uuid")
-			return
-		}
-		chirps,err := cfg.DB.GetChirpsByUserId(r.Context(),userId)
-		if err != nil{
-			respondWithError(w,http.StatusInternalServerError,"error occurred getting chirps by UserId")
-			return
-		}
-		respondWithJSON(w,http.StatusOK,chirps)
-		return
+func newChirpResponse(chirp database.Chirp) chirpResponse {
+	resp := chirpResponse{
+		ID:         chirp.ID,
+		CreatedAt:  chirp.CreatedAt,
+		UpdatedAt:  chirp.UpdatedAt,
+		Body:       chirp.Body,
+		ReplyCount: chirp.ReplyCount,
+		Kind:       chirp.Kind,
+		originalID: chirp.OriginalID,
+	}
+	if chirp.UserID.Valid {
+		resp.UserId = &chirp.UserID.UUID
 	}
-	type successResponse struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserId    uuid.UUID `json:"user_id"`
+	if chirp.ParentID.Valid {
+		resp.InReplyTo = &chirp.ParentID.UUID
 	}
+	if chirp.EditedAt.Valid {
+		resp.Edited = true
+		resp.EditedAt = &chirp.EditedAt.Time
+	}
+	return resp
+}
 
-	var resp []successResponse
+// decorateChirps adds everything to chirp responses that isn't stored on
+// the chirp row itself: reaction counts, embedded originals and attachments
+func (cfg *ApiConfig) decorateChirps(ctx context.Context, viewer uuid.NullUUID, chirps []chirpResponse) error {
+	if err := cfg.attachReactions(ctx, viewer, chirps); err != nil {
+		return err
+	}
+	if err := cfg.attachOriginals(ctx, chirps); err != nil {
+		return err
+	}
+	return cfg.attachMedia(ctx, chirps)
+}
 
-	allChirps, err := cfg.DB.GetAllChirps(r.Context())
+// GetAllChirps handles GET /api/chirps. It pages through chirps by
+// (created_at, id) using the limit, cursor and sort query parameters and
+// can be narrowed to one author with author_id. The body stays a bare
+// array; the next page's cursor comes in the X-Next-Cursor header, as
+// setNextPage explains.
+func (cfg *ApiConfig) GetAllChirps(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageParams(r)
 	if err != nil {
-		fmt.Printf("Error Creating Chirp %v\n", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	for _, chirp := range allChirps {
-		newResp := successResponse{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserId:    chirp.UserID,
+	authorID := uuid.NullUUID{}
+	if rawAuthorID := r.URL.Query().Get("author_id"); rawAuthorID != "" {
+		userId, err := uuid.Parse(rawAuthorID)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid author_id")
+			return
 		}
-		resp = append(resp, newResp)
+		authorID = uuid.NullUUID{UUID: userId, Valid: true}
 	}
 
-	successData, err := json.Marshal(resp)
+	cursorCreatedAt, cursorID := page.nullCursor()
+	// fetch one extra row to find out whether there is a next page
+	var chirps []database.Chirp
+	if page.Desc {
+		chirps, err = cfg.DB.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	} else {
+		chirps, err = cfg.DB.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	}
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Error listing chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting chirps")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(successData)
+	chirps, nextCursor := trimChirpPage(chirps, page.Limit)
+
+	resp := make([]chirpResponse, 0, len(chirps))
+	for _, chirp := range chirps {
+		resp = append(resp, newChirpResponse(chirp))
+	}
+	if err := cfg.decorateChirps(r.Context(), cfg.optionalUserID(r), resp); err != nil {
+		log.Printf("Error counting reactions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting chirps")
+		return
+	}
+
+	setNextPage(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, resp)
 }
 
 func (cfg *ApiConfig) GetAChirp(w http.ResponseWriter, r *http.Request) {
@@ -168,62 +285,22 @@ func (cfg *ApiConfig) GetAChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	type successResponse struct {
-		ID        uuid.UUID `json:"id"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-		Body      string    `json:"body"`
-		UserId    uuid.UUID `json:"user_id"`
-	}
-
 	aChirp, err := cfg.DB.GetChirp(r.Context(), paramId)
 	if err != nil {
 		fmt.Printf("Error Getting Chirp %v\n", err)
 	}
-	if aChirp.ID == uuid.Nil {
+	if aChirp.ID == uuid.Nil || aChirp.DeletedAt.Valid {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	resp := successResponse{
-		ID:        aChirp.ID,
-		CreatedAt: aChirp.CreatedAt,
-		UpdatedAt: aChirp.UpdatedAt,
-		Body:      aChirp.Body,
-		UserId:    aChirp.UserID,
-	}
-
-	successData, err := json.Marshal(resp)
-	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	resp := []chirpResponse{newChirpResponse(aChirp)}
+	if err := cfg.decorateChirps(r.Context(), cfg.optionalUserID(r), resp); err != nil {
+		log.Printf("Error counting reactions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting chirp")
 		return
 	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write(successData)
-}
-
-func validateChirp(params parameters) (string, error) {
-
-	if len(params.Body) > 140 {
-
-		return "", errors.New("body length too long")
-	}
-
-	stxt := strings.Split(params.Body, " ")
-
-	// Valid case - filter profane words
-	for idx, word := range stxt {
-		wordLower := strings.ToLower(word)
-		if wordLower == "kerfuffle" || wordLower == "sharbert" || wordLower == "fornax" {
-			stxt[idx] = "****"
-		}
-	}
-
-	formattedStr := strings.Join(stxt, " ")
-
-	return formattedStr, nil
+	respondWithJSON(w, http.StatusOK, resp[0])
 }
 
 func (cfg *ApiConfig) DeleteChirps(w http.ResponseWriter, r *http.Request) {
@@ -244,23 +321,26 @@ func (cfg *ApiConfig) DeleteChirps(w http.ResponseWriter, r *http.Request) {
         return
     }
 	
-	access_token := headerParts[1]
-	userId, err := auth.ValidateJWT(access_token, cfg.Secret)
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeChirpsWrite)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		respondWithAuthError(w, err)
 		return
 	}
 
     chirp,err := cfg.DB.GetChirp(r.Context(),chirpId)
-    if err != nil{
+    if err != nil || chirp.DeletedAt.Valid {
         respondWithError(w,http.StatusNotFound,"chirp not found")
         return
     }
-    if userId != chirp.UserID{
+    if chirp.UserID != (uuid.NullUUID{UUID: userId, Valid: true}){
         respondWithError(w,http.StatusForbidden,"you can only delete your chirp")
         return
     }
-    cfg.DB.DeleteChirp(r.Context(),chirpId)
+	if err := cfg.deleteChirp(r.Context(), chirp); err != nil {
+		log.Printf("Error deleting chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to delete chirp")
+		return
+	}
 
 	type SuccessRes struct {
 		Msg string `json:"msg"`
@@ -270,3 +350,64 @@ func (cfg *ApiConfig) DeleteChirps(w http.ResponseWriter, r *http.Request) {
 		Msg: "Chirp deleted successfuly",
 	})
 }
+
+// deleteChirp removes chirp. A chirp that has replies is replaced by a
+// tombstone instead so its thread keeps its shape.
+func (cfg *ApiConfig) deleteChirp(ctx context.Context, chirp database.Chirp) error {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	attachments, err := removeChirp(ctx, cfg.DB.WithTx(tx), chirp.ID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// files go only once the rows are gone for good
+	for _, a := range attachments {
+		cfg.deleteStoredMedia(ctx, a.StorageKey, a.ThumbnailKey.String)
+	}
+	return nil
+}
+
+// removeChirp does deleteChirp's work with q, which must be in a
+// transaction, and returns the attachments whose files to delete once it
+// commits
+func removeChirp(ctx context.Context, q *database.Queries, chirpId uuid.UUID) ([]database.DeleteChirpAttachmentsRow, error) {
+	// re-read under a row lock so a reply arriving concurrently is counted
+	chirp, err := q.GetChirpForUpdate(ctx, chirpId)
+	if err != nil {
+		return nil, err
+	}
+	// pure rechirps have nothing left to show once the original is gone;
+	// quotes stay and report the original as unavailable
+	if err := q.DeleteRechirpsOf(ctx, uuid.NullUUID{UUID: chirp.ID, Valid: true}); err != nil {
+		return nil, err
+	}
+	attachments, err := q.DeleteChirpAttachments(ctx, uuid.NullUUID{UUID: chirp.ID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	if chirp.ReplyCount > 0 {
+		if err := q.TombstoneChirp(ctx, chirp.ID); err != nil {
+			return nil, err
+		}
+		if err := q.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := q.DeleteChirp(ctx, chirp.ID); err != nil {
+			return nil, err
+		}
+		if chirp.ParentID.Valid {
+			if err := q.DecrementReplyCount(ctx, chirp.ParentID.UUID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return attachments, nil
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/mailer"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// emailVerificationTTL is how long a verification link works
+const emailVerificationTTL = 24 * time.Hour
+
+// Actions that UnverifiedRestrictions can name
+const (
+	restrictChirp  = "chirp"
+	restrictMedia  = "media"
+	restrictFollow = "follow"
+	restrictReact  = "react"
+)
+
+var errInvalidEmail = errors.New("invalid email address")
+
+// normalizeEmail checks that email is a plain address, without a display
+// name, and returns it lowercased with surrounding spaces removed, the form
+// addresses are stored and looked up in
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if len(email) > 254 {
+		return "", errInvalidEmail
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", errInvalidEmail
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", errInvalidEmail
+	}
+	return email, nil
+}
+
+// userByEmail looks up the account for an email someone typed in. An
+// address normalizeEmail refuses can't have an account, so it is
+// sql.ErrNoRows like an unknown one.
+func (cfg *ApiConfig) userByEmail(ctx context.Context, email string) (database.User, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return database.User{}, sql.ErrNoRows
+	}
+	return cfg.DB.GetUserByEmail(ctx, email)
+}
+
+// startEmailVerification mails a link confirming userId owns email. Any
+// earlier link still outstanding stops working.
+func (cfg *ApiConfig) startEmailVerification(ctx context.Context, userId uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+	if err := cfg.DB.ExpireEmailVerifications(ctx, userId); err != nil {
+		return err
+	}
+	err = cfg.DB.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		TokenHash: cfg.hashToken(token),
+		UserID:    userId,
+		Email:     email,
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := cfg.publicLink("/app/verify-email", url.Values{"token": {token}})
+	cfg.sendMail(mailer.Message{
+		To:      email,
+		Subject: "Confirm your email for Chirpy",
+		Body: fmt.Sprintf("Open this link within the next 24 hours to confirm this is your "+
+			"address:\n\n%s\n\nIf you didn't sign up for Chirpy or change your email, you can "+
+			"ignore this email.\n", link),
+	})
+	return nil
+}
+
+// requireVerifiedEmail responds with 403 and returns false when action is
+// one unverified accounts may not take and userId hasn't verified yet
+func (cfg *ApiConfig) requireVerifiedEmail(w http.ResponseWriter, r *http.Request, userId uuid.UUID, action string) bool {
+	if !cfg.UnverifiedRestrictions[action] {
+		return true
+	}
+	user, err := cfg.DB.GetUserByID(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return false
+	}
+	if !user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "verify your email address first")
+		return false
+	}
+	return true
+}
+
+// VerifyEmailPage handles GET /app/verify-email, where emailed
+// verification links point, with a button that posts the token to
+// VerifyEmail
+func (cfg *ApiConfig) VerifyEmailPage(w http.ResponseWriter, r *http.Request) {
+	renderTokenPage(w, http.StatusOK, tokenPage{
+		Title:    "Confirm your email",
+		Messages: []string{"Press the button to confirm this is your email address."},
+		Action:   "/api/users/verify-email",
+		Fields:   map[string]string{"token": r.URL.Query().Get("token")},
+		Button:   "Confirm",
+	})
+}
+
+var (
+	errVerificationInvalid = errors.New("invalid or expired verification token")
+	errEmailTaken          = errors.New("email is already in use")
+)
+
+// VerifyEmail handles POST /api/users/verify-email with the token from a
+// verification link. For an email change this is when the new address
+// replaces the old one. The token comes as JSON from clients or as a form
+// from VerifyEmailPage, which gets a page back.
+func (cfg *ApiConfig) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+	type respBody struct {
+		UserId          uuid.UUID `json:"user_id"`
+		Email           string    `json:"email"`
+		EmailVerifiedAt time.Time `json:"email_verified_at"`
+	}
+	params := parameters{}
+	form := isFormPost(r)
+	if form {
+		if err := r.ParseForm(); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		params.Token = r.PostForm.Get("token")
+	} else if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, err := cfg.verifyEmail(r.Context(), params.Token)
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, errVerificationInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(err, errEmailTaken):
+		status = http.StatusConflict
+	case err != nil:
+		log.Printf("Error verifying email: %v", err)
+		status = http.StatusInternalServerError
+	}
+
+	if form {
+		page := tokenPage{Title: "Email confirmed", Messages: []string{"Thanks, your email address " + user.Email + " is confirmed."}}
+		switch {
+		case errors.Is(err, errVerificationInvalid):
+			page = tokenPage{Title: "Confirm your email", Messages: []string{"This link is invalid or has expired. You can ask for a new one from your account settings."}}
+		case errors.Is(err, errEmailTaken):
+			page = tokenPage{Title: "Confirm your email", Messages: []string{"This email address is already used by another account."}}
+		case err != nil:
+			page = tokenPage{Title: "Confirm your email", Messages: []string{"Something went wrong confirming your email. Please try again."}}
+		}
+		renderTokenPage(w, status, page)
+		return
+	}
+	if status == http.StatusInternalServerError {
+		respondWithError(w, status, "failed to verify email")
+		return
+	}
+	if err != nil {
+		respondWithError(w, status, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, respBody{
+		UserId:          user.ID,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt.Time,
+	})
+}
+
+// verifyEmail marks the address token was issued for as verified and
+// returns the updated user
+func (cfg *ApiConfig) verifyEmail(ctx context.Context, token string) (database.User, error) {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	verification, err := qtx.UseEmailVerification(ctx, cfg.hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errVerificationInvalid
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	user, err := qtx.SetVerifiedEmail(ctx, database.SetVerifiedEmailParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return database.User{}, errEmailTaken
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := qtx.ExpireEmailVerifications(ctx, user.ID); err != nil {
+		return database.User{}, err
+	}
+	return user, tx.Commit()
+}
+
+// ResendEmailVerification handles POST /api/users/verify-email/resend for
+// an account whose address isn't verified yet
+func (cfg *ApiConfig) ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeProfileWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	user, err := cfg.DB.GetUserByID(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "email is already verified")
+		return
+	}
+	if err := cfg.startEmailVerification(r.Context(), user.ID, user.Email); err != nil {
+		log.Printf("Error starting email verification: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to send verification email")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/mailer"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	valid := map[string]string{
+		"walt@breakingbad.com":     "walt@breakingbad.com",
+		"  walt@breakingbad.com  ": "walt@breakingbad.com",
+		"saul+law@bettercall.co":   "saul+law@bettercall.co",
+		"Walt@BreakingBad.com":     "walt@breakingbad.com",
+	}
+	for in, want := range valid {
+		got, err := normalizeEmail(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got)
+	}
+
+	for _, in := range []string{
+		"",
+		"walt",
+		"walt@",
+		"walt@localhost",
+		"walt@.com",
+		"Walter White <walt@breakingbad.com>",
+		"walt@breakingbad.com, jesse@breakingbad.com",
+	} {
+		_, err := normalizeEmail(in)
+		assert.ErrorIs(t, err, errInvalidEmail, in)
+	}
+}
+
+func TestUserByEmailNormalizes(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	_, err := cfg.userByEmail(context.Background(), " Walt@BreakingBad.com ")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	calls := db.called("GetUserByEmail")
+	require.Len(t, calls, 1)
+	assert.Equal(t, "walt@breakingbad.com", calls[0].Args[0])
+
+	// an address that can't be registered isn't looked up
+	_, err = cfg.userByEmail(context.Background(), "Walter White <walt@breakingbad.com>")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.Len(t, db.called("GetUserByEmail"), 1)
+}
+
+func TestEmailVerificationLink(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	mail := fakeMailer(make(chan mailer.Message, 1))
+	cfg.Mailer, cfg.PublicURL = mail, "https://chirpy.example"
+	user := database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Email: "walt@breakingbad.com", Role: "user"}
+	db.on("GetUserByID", user)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/users/verify-email/resend", nil)
+	cfg.ResendEmailVerification(w, authorize(t, cfg, r, user.ID))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	link := mailedLink(t, mail, cfg)
+	assert.Equal(t, "/app/verify-email", link.Path)
+	token := link.Query().Get("token")
+
+	// mail scanners opening the link don't verify anything
+	w = httptest.NewRecorder()
+	cfg.VerifyEmailPage(w, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `action="/api/users/verify-email"`)
+	assert.Contains(t, w.Body.String(), `value="`+token+`"`)
+	assert.Empty(t, db.called("UseEmailVerification"))
+
+	db.on("UseEmailVerification", database.EmailVerification{
+		ID: uuid.New(), TokenHash: cfg.hashToken(token), UserID: user.ID, Email: user.Email,
+		ExpiresAt: fakeNow.Add(time.Hour), CreatedAt: fakeNow,
+	})
+	verified := user
+	verified.EmailVerifiedAt = sql.NullTime{Time: fakeNow, Valid: true}
+	db.on("SetVerifiedEmail", verified)
+
+	r = httptest.NewRequest(http.MethodPost, "/api/users/verify-email", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	cfg.VerifyEmail(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Email confirmed")
+	assert.Len(t, db.called("SetVerifiedEmail"), 1)
+
+	// a used token gets a page saying so
+	db.on("UseEmailVerification")
+	r = httptest.NewRequest(http.MethodPost, "/api/users/verify-email", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	cfg.VerifyEmail(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid or has expired")
+}
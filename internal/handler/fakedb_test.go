@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/mailer"
+	"github.com/google/uuid"
+)
+
+// fakeDB stands in for Postgres in handler tests. sqlc starts every query
+// with "-- name: <Name>", so tests script what each named query returns
+// and look at the calls afterwards to see what was written. Queries that
+// aren't scripted return no rows and report one row affected.
+type fakeDB struct {
+	mu      sync.Mutex
+	results map[string]func(args []driver.Value) ([][]driver.Value, error)
+	calls   []fakeCall
+}
+
+type fakeCall struct {
+	Name string
+	Args []driver.Value
+}
+
+// newFakeConfig returns an ApiConfig whose DB and Conn are backed by a
+// new fakeDB
+func newFakeConfig(t *testing.T) (*ApiConfig, *fakeDB) {
+	db := &fakeDB{results: map[string]func([]driver.Value) ([][]driver.Value, error){}}
+	conn := sql.OpenDB(db)
+	t.Cleanup(func() { conn.Close() })
+	return &ApiConfig{DB: database.New(conn), Conn: conn, Secret: "secret"}, db
+}
+
+// authorize signs the request in as userId with a session token
+func authorize(t *testing.T, cfg *ApiConfig, r *http.Request, userId uuid.UUID) *http.Request {
+	token, err := cfg.signAccessToken(auth.AccessClaims{UserID: userId, SessionID: uuid.New()}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+// fakeMailer hands sent messages to the test
+type fakeMailer chan mailer.Message
+
+func (m fakeMailer) Send(_ context.Context, msg mailer.Message) error {
+	m <- msg
+	return nil
+}
+
+// mailedLink waits for a message sent through cfg and returns the link in
+// it that starts with cfg.PublicURL
+func mailedLink(t *testing.T, mail fakeMailer, cfg *ApiConfig) *url.URL {
+	t.Helper()
+	select {
+	case msg := <-mail:
+		for _, field := range strings.Fields(msg.Body) {
+			if strings.HasPrefix(field, cfg.PublicURL+"/") {
+				link, err := url.Parse(field)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return link
+			}
+		}
+		t.Fatalf("no link in mail: %s", msg.Body)
+	case <-time.After(time.Second):
+		t.Fatal("no mail sent")
+	}
+	return nil
+}
+
+// on makes the query name return rows, each a model such as a
+// database.User or a slice of column values
+func (db *fakeDB) on(name string, rows ...interface{}) {
+	values := make([][]driver.Value, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, rowValues(row))
+	}
+	db.onFunc(name, func([]driver.Value) ([][]driver.Value, error) { return values, nil })
+}
+
+// onFunc makes the query name answer with f
+func (db *fakeDB) onFunc(name string, f func(args []driver.Value) ([][]driver.Value, error)) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.results[name] = f
+}
+
+// called returns the calls made to the query name
+func (db *fakeDB) called(name string) []fakeCall {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var calls []fakeCall
+	for _, c := range db.calls {
+		if c.Name == name {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func (db *fakeDB) run(query string, args []driver.Value) ([][]driver.Value, error) {
+	name := queryName(query)
+	db.mu.Lock()
+	db.calls = append(db.calls, fakeCall{Name: name, Args: args})
+	f := db.results[name]
+	db.mu.Unlock()
+	if f == nil {
+		return nil, nil
+	}
+	return f(args)
+}
+
+func queryName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) >= 3 && fields[0] == "--" && fields[1] == "name:" {
+		return fields[2]
+	}
+	return query
+}
+
+// rowValues flattens a model into the column values sqlc scans it from
+func rowValues(row interface{}) []driver.Value {
+	if values, ok := row.([]driver.Value); ok {
+		return values
+	}
+	v := reflect.ValueOf(row)
+	if v.Kind() != reflect.Struct {
+		return []driver.Value{columnValue(row)}
+	}
+	values := make([]driver.Value, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		values = append(values, columnValue(v.Field(i).Interface()))
+	}
+	return values
+}
+
+func columnValue(field interface{}) driver.Value {
+	switch f := field.(type) {
+	case uuid.UUID:
+		return f.String()
+	case uuid.NullUUID:
+		if !f.Valid {
+			return nil
+		}
+		return f.UUID.String()
+	case []string:
+		return []byte("{" + strings.Join(f, ",") + "}")
+	case int32:
+		return int64(f)
+	case float32:
+		return float64(f)
+	case driver.Valuer:
+		value, err := f.Value()
+		if err != nil {
+			panic(err)
+		}
+		return value
+	default:
+		return field
+	}
+}
+
+// database/sql driver plumbing
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return fakeDriver{db} }
+
+type fakeDriver struct{ db *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d.db}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.db.run(query, namedValues(args))
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	rows, err := c.db.run(query, namedValues(args))
+	if err != nil {
+		return nil, err
+	}
+	affected := int64(1)
+	if rows != nil {
+		affected = int64(len(rows))
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, 0, len(args))
+	for _, a := range args {
+		values = append(values, a.Value)
+	}
+	return values
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("fakedb: unexpected prepared exec")
+}
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, fmt.Errorf("fakedb: unexpected prepared query")
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	rows [][]driver.Value
+	next int
+}
+
+func (r *fakeRows) Columns() []string {
+	n := 1
+	if len(r.rows) > 0 {
+		n = len(r.rows[0])
+	}
+	columns := make([]string, n)
+	for i := range columns {
+		columns[i] = fmt.Sprintf("c%d", i)
+	}
+	return columns
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+// fakeNow is a fixed time for rows, to the microsecond like Postgres
+var fakeNow = time.Now().UTC().Truncate(time.Microsecond)
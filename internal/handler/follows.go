@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type followResponse struct {
+	UserID      uuid.UUID `json:"user_id"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	FollowedAt  time.Time `json:"followed_at"`
+}
+
+// FollowUser handles POST /api/users/{id}/follow
+func (cfg *ApiConfig) FollowUser(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeFollowsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	followeeId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	if followeeId == userId {
+		respondWithError(w, http.StatusBadRequest, "you cannot follow yourself")
+		return
+	}
+	if !cfg.requireVerifiedEmail(w, r, userId, restrictFollow) {
+		return
+	}
+
+	_, err = cfg.DB.GetUserByID(r.Context(), followeeId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to follow user")
+		return
+	}
+
+	err = cfg.DB.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userId,
+		FolloweeID: followeeId,
+	})
+	if err != nil {
+		log.Printf("Error following user: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to follow user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnfollowUser handles DELETE /api/users/{id}/follow
+func (cfg *ApiConfig) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeFollowsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	followeeId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	err = cfg.DB.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userId,
+		FolloweeID: followeeId,
+	})
+	if err != nil {
+		log.Printf("Error unfollowing user: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to unfollow user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFollowers handles GET /api/users/{id}/followers, newest follow first
+func (cfg *ApiConfig) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, func(userId uuid.UUID, page pageParams) ([]database.ListFollowingRow, error) {
+		cursorCreatedAt, cursorID := page.nullCursor()
+		rows, err := cfg.DB.ListFollowers(r.Context(), database.ListFollowersParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+		following := make([]database.ListFollowingRow, 0, len(rows))
+		for _, row := range rows {
+			following = append(following, database.ListFollowingRow(row))
+		}
+		return following, err
+	})
+}
+
+// GetFollowing handles GET /api/users/{id}/following, newest follow first
+func (cfg *ApiConfig) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	cfg.listFollows(w, r, func(userId uuid.UUID, page pageParams) ([]database.ListFollowingRow, error) {
+		cursorCreatedAt, cursorID := page.nullCursor()
+		return cfg.DB.ListFollowing(r.Context(), database.ListFollowingParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	})
+}
+
+func (cfg *ApiConfig) listFollows(w http.ResponseWriter, r *http.Request, list func(uuid.UUID, pageParams) ([]database.ListFollowingRow, error)) {
+	userId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := list(userId, page)
+	if err != nil {
+		log.Printf("Error listing follows: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting follows")
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > int(page.Limit) {
+		rows = rows[:page.Limit]
+		last := rows[len(rows)-1]
+		nextCursor = encodeCursor(last.FollowedAt, last.ID)
+	}
+
+	resp := make([]followResponse, 0, len(rows))
+	for _, row := range rows {
+		resp = append(resp, followResponse{
+			UserID:      row.ID,
+			IsChirpyRed: row.IsChirpyRed,
+			FollowedAt:  row.FollowedAt,
+		})
+	}
+
+	setNextPage(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// GetHomeTimeline handles GET /api/timeline: chirps from the authenticated
+// user and everyone they follow, newest first unless sort=asc
+func (cfg *ApiConfig) GetHomeTimeline(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeChirpsRead)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.URL.Query().Get("sort") == "" {
+		page.Desc = true
+	}
+
+	cursorCreatedAt, cursorID := page.nullCursor()
+	var chirps []database.Chirp
+	if page.Desc {
+		chirps, err = cfg.DB.GetHomeTimelineDesc(r.Context(), database.GetHomeTimelineDescParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	} else {
+		chirps, err = cfg.DB.GetHomeTimelineAsc(r.Context(), database.GetHomeTimelineAscParams{
+			UserID:          userId,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	}
+	if err != nil {
+		log.Printf("Error getting timeline: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting timeline")
+		return
+	}
+
+	chirps, nextCursor := trimChirpPage(chirps, page.Limit)
+	resp := make([]chirpResponse, 0, len(chirps))
+	for _, chirp := range chirps {
+		resp = append(resp, newChirpResponse(chirp))
+	}
+	if err := cfg.decorateChirps(r.Context(), cfg.optionalUserID(r), resp); err != nil {
+		log.Printf("Error counting reactions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting timeline")
+		return
+	}
+
+	setNextPage(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, resp)
+}
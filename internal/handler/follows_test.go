@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTimeline answers GetHomeTimelineDesc the way its SQL does: chirps by
+// the user or anyone they follow, not deleted, newest first from the cursor
+func fakeTimeline(db *fakeDB, follows map[uuid.UUID][]uuid.UUID, chirps []database.Chirp) {
+	db.onFunc("GetHomeTimelineDesc", func(args []driver.Value) ([][]driver.Value, error) {
+		userId := uuid.MustParse(args[0].(string))
+		authors := map[uuid.UUID]bool{userId: true}
+		for _, followee := range follows[userId] {
+			authors[followee] = true
+		}
+		sorted := append([]database.Chirp(nil), chirps...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].CreatedAt.After(sorted[j].CreatedAt) })
+
+		var rows [][]driver.Value
+		for _, chirp := range sorted {
+			if !authors[chirp.UserID.UUID] || chirp.DeletedAt.Valid {
+				continue
+			}
+			if cursor, ok := args[1].(time.Time); ok && !chirp.CreatedAt.Before(cursor) {
+				continue
+			}
+			if int64(len(rows)) == args[3].(int64) {
+				break
+			}
+			rows = append(rows, rowValues(chirp))
+		}
+		return rows, nil
+	})
+}
+
+func TestHomeTimeline(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	db.on("GetUserByID", database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Role: "user"})
+
+	walt, jesse, saul := uuid.New(), uuid.New(), uuid.New()
+	var chirps []database.Chirp
+	post := func(userId uuid.UUID, age time.Duration, deleted bool) database.Chirp {
+		chirp := database.Chirp{ID: uuid.New(), CreatedAt: fakeNow.Add(-age), UpdatedAt: fakeNow, UserID: uuid.NullUUID{UUID: userId, Valid: true}, Body: "chirp", Kind: chirpKindChirp}
+		if deleted {
+			chirp.DeletedAt = sql.NullTime{Time: fakeNow, Valid: true}
+		}
+		chirps = append(chirps, chirp)
+		return chirp
+	}
+	own := post(walt, 1*time.Minute, false)
+	followed := post(jesse, 2*time.Minute, false)
+	post(saul, 3*time.Minute, false)
+	post(jesse, 4*time.Minute, true)
+	older := post(walt, 5*time.Minute, false)
+	// walt follows jesse, and saul follows walt, which doesn't work backwards
+	fakeTimeline(db, map[uuid.UUID][]uuid.UUID{walt: {jesse}, saul: {walt}}, chirps)
+
+	var ids []uuid.UUID
+	target := "/api/timeline?limit=2"
+	for target != "" {
+		w := httptest.NewRecorder()
+		cfg.GetHomeTimeline(w, authorize(t, cfg, httptest.NewRequest(http.MethodGet, target, nil), walt))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page []chirpResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+		for _, chirp := range page {
+			ids = append(ids, chirp.ID)
+		}
+		target = ""
+		if cursor := w.Header().Get("X-Next-Cursor"); cursor != "" {
+			target = "/api/timeline?limit=2&cursor=" + cursor
+		}
+	}
+	assert.Equal(t, []uuid.UUID{own.ID, followed.ID, older.ID}, ids)
+
+	// the timeline is always the caller's own
+	for _, call := range db.called("GetHomeTimelineDesc") {
+		assert.Equal(t, walt.String(), call.Args[0])
+	}
+}
+
+func TestHomeTimelineNeedsLogin(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	w := httptest.NewRecorder()
+	cfg.GetHomeTimeline(w, httptest.NewRequest(http.MethodGet, "/api/timeline", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, db.called("GetHomeTimelineDesc"))
+}
@@ -2,19 +2,68 @@ package handler
 
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
+	"sync"
 	"sync/atomic"
+	"time"
 	"encoding/json"
 
+	"github.com/Glenn444/chirpy/internal/auth"
 	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/lockout"
+	"github.com/Glenn444/chirpy/internal/mailer"
+	"github.com/Glenn444/chirpy/internal/media"
+	"github.com/Glenn444/chirpy/internal/moderation"
+	"github.com/Glenn444/chirpy/internal/oidc"
+	"github.com/Glenn444/chirpy/internal/password"
+	"github.com/google/uuid"
 )
 
 type ApiConfig struct{
 	FileserverHits atomic.Int32
 	DB *database.Queries
+	// Conn is the pool DB was built from, used to open transactions
+	Conn *sql.DB
 	Platform string
 	Secret string
+	// Keys signs and verifies access tokens; when nil they are HS256
+	// tokens keyed with Secret
+	Keys *auth.KeySet
+	// RefreshTokenKey keys the hashes refresh tokens are stored as;
+	// Secret is used when it is empty
+	RefreshTokenKey string
 	ApiKey string
+	// Moderator checks chirp bodies; nil means moderation.Default()
+	Moderator *moderation.Moderator
+	// moderatorOnce fills in the default Moderator once, so concurrent
+	// requests don't race to set it
+	moderatorOnce sync.Once
+	// ModerationWordList is an optional word list file loaded alongside
+	// the rules stored in the database
+	ModerationWordList string
+	// Media stores uploaded images; uploads are disabled when nil
+	Media media.Store
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For
+	TrustProxyHeaders bool
+	// Mailer sends account emails; they are dropped when nil
+	Mailer mailer.Mailer
+	// PublicURL is where the site is reachable, used to build links in
+	// emails
+	PublicURL string
+	// UnverifiedRestrictions names the actions accounts without a verified
+	// email may not take: "chirp", "media", "follow" and "react"
+	UnverifiedRestrictions map[string]bool
+	// Lockout slows down password guessing on login; nil turns it off
+	Lockout *lockout.Guard
+	// PasswordPolicy judges new passwords; nil means password.DefaultPolicy()
+	PasswordPolicy *password.Policy
+	// passwordPolicyOnce fills in the default PasswordPolicy once
+	passwordPolicyOnce sync.Once
+	// OIDCProviders are the OpenID Connect providers users can log in
+	// with, by name
+	OIDCProviders map[string]*oidc.Provider
 }
 
 //middleware for metrics
@@ -26,6 +75,97 @@ func (cfg *ApiConfig) MiddlewareMetricsInc(next http.Handler) http.Handler {
 	})
 }
 
+// makeAccessToken issues an access token for userId's session, carrying
+// the user's role
+func (cfg *ApiConfig) makeAccessToken(userId, sessionId uuid.UUID, role string, expiresIn time.Duration) (string, error) {
+	return cfg.signAccessToken(auth.AccessClaims{UserID: userId, SessionID: sessionId, Role: role}, expiresIn)
+}
+
+// signAccessToken issues an access token described by claims
+func (cfg *ApiConfig) signAccessToken(claims auth.AccessClaims, expiresIn time.Duration) (string, error) {
+	if cfg.Keys != nil {
+		return cfg.Keys.MakeAccessJWT(claims, expiresIn)
+	}
+	return auth.MakeAccessJWT(claims, cfg.Secret, expiresIn)
+}
+
+// parseAccessToken checks an access token of either kind, from logging in
+// or issued to an OAuth client, and returns what it claims
+func (cfg *ApiConfig) parseAccessToken(token string) (auth.AccessClaims, error) {
+	if cfg.Keys != nil {
+		return cfg.Keys.ParseAccessJWT(token)
+	}
+	return auth.ParseAccessJWT(token, cfg.Secret)
+}
+
+// validateAccessToken returns the user and session an access token from
+// logging in was issued for. Tokens issued to OAuth clients are refused.
+func (cfg *ApiConfig) validateAccessToken(token string) (uuid.UUID, uuid.UUID, error) {
+	claims, err := cfg.parseAccessToken(token)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if claims.ClientID != "" {
+		return uuid.Nil, uuid.Nil, errOAuthAccessToken
+	}
+	return claims.UserID, claims.SessionID, nil
+}
+
+// authenticate returns the user behind the request's bearer token, which
+// may be an access token, or a personal access token or OAuth access token
+// granted scope, and the login session it belongs to (uuid.Nil for the
+// latter two). Suspended users are refused whatever the token.
+func (cfg *ApiConfig) authenticate(r *http.Request, scope string) (uuid.UUID, uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if auth.IsPersonalAccessToken(token) {
+		userId, err := cfg.validatePersonalAccessToken(r.Context(), token, scope)
+		if err != nil {
+			return uuid.Nil, uuid.Nil, err
+		}
+		return userId, uuid.Nil, cfg.checkNotSuspended(r.Context(), userId)
+	}
+	claims, err := cfg.parseAccessToken(token)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if claims.ClientID != "" {
+		if err := cfg.checkOAuthAccessToken(r.Context(), claims, scope); err != nil {
+			return uuid.Nil, uuid.Nil, err
+		}
+		return claims.UserID, uuid.Nil, cfg.checkNotSuspended(r.Context(), claims.UserID)
+	}
+	return claims.UserID, claims.SessionID, cfg.checkNotSuspended(r.Context(), claims.UserID)
+}
+
+// authenticatedUserID is authenticate for handlers that don't need the session
+func (cfg *ApiConfig) authenticatedUserID(r *http.Request, scope string) (uuid.UUID, error) {
+	userId, _, err := cfg.authenticate(r, scope)
+	return userId, err
+}
+
+// respondWithAuthError answers a failed authenticate: 403 when the token
+// is fine but lacks the scope or the account is suspended, 401 otherwise
+func respondWithAuthError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errInsufficientScope) || errors.Is(err, errAccountSuspended) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	respondWithError(w, http.StatusUnauthorized, "invalid access token")
+}
+
+// optionalUserID is like authenticatedUserID for endpoints that also serve
+// anonymous callers; a missing or invalid token yields an invalid NullUUID
+func (cfg *ApiConfig) optionalUserID(r *http.Request) uuid.NullUUID {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeChirpsRead)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userId, Valid: true}
+}
+
 // respondWithError sends an error response back to the client
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	respondWithJSON(w, code, map[string]string{"error": message})
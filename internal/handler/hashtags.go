@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Glenn444/chirpy/internal/database"
+)
+
+const (
+	maxHashtagLength      = 64
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+)
+
+// extractHashtags returns the lower-cased, de-duplicated #tags in body in
+// the order they first appear. A tag is a # at the start of a word followed
+// by letters, digits or underscores, and must contain at least one letter.
+func extractHashtags(body string) []string {
+	isTagRune := func(c rune) bool {
+		return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+	}
+
+	var tags []string
+	seen := map[string]bool{}
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '#' || (i > 0 && (isTagRune(runes[i-1]) || runes[i-1] == '#')) {
+			continue
+		}
+		start := i + 1
+		end := start
+		hasLetter := false
+		for end < len(runes) && isTagRune(runes[end]) {
+			if unicode.IsLetter(runes[end]) {
+				hasLetter = true
+			}
+			end++
+		}
+		i = end - 1
+		if !hasLetter || end-start > maxHashtagLength {
+			continue
+		}
+		tag := strings.ToLower(string(runes[start:end]))
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// saveChirpHashtags links chirp to every hashtag in its body, creating
+// hashtags the first time they are used
+func saveChirpHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	for _, tag := range extractHashtags(chirp.Body) {
+		hashtag, err := q.UpsertHashtag(ctx, tag)
+		if err != nil {
+			return err
+		}
+		err = q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:   chirp.ID,
+			HashtagID: hashtag.ID,
+			CreatedAt: chirp.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetHashtagChirps handles GET /api/hashtags/{tag}/chirps and pages like GET /api/chirps
+func (cfg *ApiConfig) GetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "tag is required")
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.nullCursor()
+	var chirps []database.Chirp
+	if page.Desc {
+		chirps, err = cfg.DB.ListChirpsByHashtagDesc(r.Context(), database.ListChirpsByHashtagDescParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	} else {
+		chirps, err = cfg.DB.ListChirpsByHashtagAsc(r.Context(), database.ListChirpsByHashtagAscParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	}
+	if err != nil {
+		log.Printf("Error listing chirps for #%s: %v", tag, err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting chirps")
+		return
+	}
+
+	chirps, nextCursor := trimChirpPage(chirps, page.Limit)
+
+	resp := make([]chirpResponse, 0, len(chirps))
+	for _, chirp := range chirps {
+		resp = append(resp, newChirpResponse(chirp))
+	}
+	if err := cfg.decorateChirps(r.Context(), cfg.optionalUserID(r), resp); err != nil {
+		log.Printf("Error counting reactions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting chirps")
+		return
+	}
+
+	setNextPage(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// GetTrendingHashtags handles GET /api/hashtags/trending. window is a Go
+// duration such as 6h (default 24h, at most 7 days) and limit caps the
+// number of tags returned.
+func (cfg *ApiConfig) GetTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if rawWindow := r.URL.Query().Get("window"); rawWindow != "" {
+		parsed, err := time.ParseDuration(rawWindow)
+		if err != nil || parsed <= 0 {
+			respondWithError(w, http.StatusBadRequest, "window must be a positive duration such as 6h")
+			return
+		}
+		window = min(parsed, maxTrendingWindow)
+	}
+
+	limit := defaultTrendingLimit
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 {
+			respondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, maxPageLimit)
+	}
+
+	trending, err := cfg.DB.ListTrendingHashtags(r.Context(), database.ListTrendingHashtagsParams{
+		Since: time.Now().UTC().Add(-window),
+		Limit: int32(limit),
+	})
+	if err != nil {
+		log.Printf("Error listing trending hashtags: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting trending hashtags")
+		return
+	}
+
+	type trendingResponse struct {
+		Tag        string `json:"tag"`
+		ChirpCount int64  `json:"chirp_count"`
+	}
+	resp := make([]trendingResponse, 0, len(trending))
+	for _, t := range trending {
+		resp = append(resp, trendingResponse{Tag: t.Tag, ChirpCount: t.ChirpCount})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"no tags here", nil},
+		{"#Go is #fun", []string{"go", "fun"}},
+		{"dupes #go #GO #go!", []string{"go"}},
+		{"email me@example#com and C# and ##double", nil},
+		{"numbers only #2025 but #web3 counts", []string{"web3"}},
+		{"unicode #café_time.", []string{"café_time"}},
+		{"#" + strings.Repeat("a", maxHashtagLength+1), nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, extractHashtags(tt.body), tt.body)
+	}
+}
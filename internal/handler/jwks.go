@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+)
+
+// JWKS handles GET /.well-known/jwks.json, publishing the public keys other
+// services can verify Chirpy access tokens with. It is empty while tokens
+// are signed with the shared secret.
+func (cfg *ApiConfig) JWKS(w http.ResponseWriter, r *http.Request) {
+	set := auth.JWKS{Keys: []auth.JWK{}}
+	if cfg.Keys != nil {
+		set = cfg.Keys.JWKS()
+	}
+	// verifiers may cache, but should notice a new key within minutes
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, set)
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/lockout"
+)
+
+// retryAfter formats d for a Retry-After header, in whole seconds
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// loginLockoutWait returns how long email or the caller's IP is still
+// locked out for. Logins go ahead if the lockout store fails.
+func (cfg *ApiConfig) loginLockoutWait(r *http.Request, email string) time.Duration {
+	if cfg.Lockout == nil {
+		return 0
+	}
+	wait, err := cfg.Lockout.Check(r.Context(), email, cfg.clientIP(r))
+	if err != nil {
+		log.Printf("Error checking login lockout: %v", err)
+		return 0
+	}
+	return wait
+}
+
+// checkLoginLockout responds with 429 and returns false while email or the
+// caller's IP is locked out
+func (cfg *ApiConfig) checkLoginLockout(w http.ResponseWriter, r *http.Request, email string) bool {
+	if wait := cfg.loginLockoutWait(r, email); wait > 0 {
+		w.Header().Set("Retry-After", retryAfter(wait))
+		respondWithError(w, http.StatusTooManyRequests, "too many failed login attempts, try again later")
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed login and, when that starts a
+// lockout, says when to retry in the Retry-After header
+func (cfg *ApiConfig) recordLoginFailure(w http.ResponseWriter, r *http.Request, email string) {
+	if cfg.Lockout == nil {
+		return
+	}
+	wait, err := cfg.Lockout.Fail(r.Context(), email, cfg.clientIP(r))
+	if err != nil {
+		log.Printf("Error recording login failure: %v", err)
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", retryAfter(wait))
+	}
+}
+
+// recordLoginSuccess clears the account's failures
+func (cfg *ApiConfig) recordLoginSuccess(r *http.Request, email string) {
+	if cfg.Lockout == nil {
+		return
+	}
+	if err := cfg.Lockout.Succeed(r.Context(), email); err != nil {
+		log.Printf("Error clearing login failures: %v", err)
+	}
+}
+
+// UnlockLogin handles POST /admin/login-lockouts/unlock, clearing the
+// failures and lockout of an account, a client IP or both
+func (cfg *ApiConfig) UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+		IP    string `json:"ip"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if params.Email == "" && params.IP == "" {
+		respondWithError(w, http.StatusBadRequest, "email or ip is required")
+		return
+	}
+	if cfg.Lockout == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if params.Email != "" {
+		if err := cfg.Lockout.Store.Reset(r.Context(), lockout.AccountKey(params.Email)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to unlock")
+			return
+		}
+	}
+	if params.IP != "" {
+		if err := cfg.Lockout.Store.Reset(r.Context(), lockout.IPKey(params.IP)); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to unlock")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/mailer"
+)
+
+const (
+	// magicLinkTTL is how long a login link works
+	magicLinkTTL = 15 * time.Minute
+	// maxMagicLinks links may be sent to one account per magicLinkWindow
+	maxMagicLinks   = 3
+	magicLinkWindow = 15 * time.Minute
+	// magicLinkExpiry is the access token lifetime, in seconds, the same
+	// as LoginUser's default
+	magicLinkExpiry = 3600
+)
+
+// RequestMagicLink handles POST /api/login/magic-link by mailing a link
+// that logs the account in without its password. Like ForgotPassword it
+// answers the same whether or not the email has an account, and also when
+// the account has had too many links lately, so it can't be used to find
+// out who is registered or to flood an inbox.
+func (cfg *ApiConfig) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, err := cfg.userByEmail(r.Context(), params.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to send login link")
+		return
+	}
+	recent, err := cfg.DB.CountRecentMagicLinks(r.Context(), database.CountRecentMagicLinksParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(-magicLinkWindow),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to send login link")
+		return
+	}
+	if recent >= maxMagicLinks {
+		log.Printf("Not sending login link to %s: %d sent in the last %v", user.Email, recent, magicLinkWindow)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to send login link")
+		return
+	}
+	// only the newest link works
+	if err := cfg.DB.ExpireMagicLinks(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to send login link")
+		return
+	}
+	err = cfg.DB.CreateMagicLink(r.Context(), database.CreateMagicLinkParams{
+		TokenHash: cfg.hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(magicLinkTTL),
+	})
+	if err != nil {
+		log.Printf("Error saving login link: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to send login link")
+		return
+	}
+
+	link := cfg.publicLink("/app/magic-link", url.Values{"token": {token}})
+	cfg.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy login link",
+		Body: fmt.Sprintf("Open this link within the next 15 minutes and press \"Log in\" to log in "+
+			"to Chirpy:\n\n%s\n\nThe link works once. If you didn't ask for it, you can ignore "+
+			"this email.\n", link),
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+var errMagicLinkInvalid = errors.New("invalid or expired login link")
+
+// checkMagicLink decides whether a stored login link can still be used
+func checkMagicLink(link database.MagicLink, now time.Time) error {
+	if link.UsedAt.Valid || !now.Before(link.ExpiresAt) {
+		return errMagicLinkInvalid
+	}
+	return nil
+}
+
+// MagicLinkPage handles GET /app/magic-link, where emailed login links
+// point, with a button that posts the token to LoginMagicLink
+func (cfg *ApiConfig) MagicLinkPage(w http.ResponseWriter, r *http.Request) {
+	renderTokenPage(w, http.StatusOK, tokenPage{
+		Title:    "Log in to Chirpy",
+		Messages: []string{"Press the button to finish logging in. The link works once."},
+		Action:   "/api/login/magic-link/verify",
+		Fields:   map[string]string{"token": r.URL.Query().Get("token")},
+		Button:   "Log in",
+	})
+}
+
+// LoginMagicLink handles POST /api/login/magic-link/verify, exchanging the
+// token from a login link for the tokens LoginUser issues, or for an MFA
+// challenge when the account has 2FA on. The token comes as JSON from
+// clients or as a form from MagicLinkPage.
+func (cfg *ApiConfig) LoginMagicLink(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+	params := parameters{}
+	if isFormPost(r) {
+		if err := r.ParseForm(); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		params.Token = r.PostForm.Get("token")
+	} else if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	// the row lock makes a link race itself only once
+	link, err := qtx.GetMagicLinkForUpdate(r.Context(), cfg.hashToken(params.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = errMagicLinkInvalid
+	}
+	if err == nil {
+		err = checkMagicLink(link, time.Now())
+	}
+	if errors.Is(err, errMagicLinkInvalid) {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if err := qtx.UseMagicLink(r.Context(), link.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	user, err := qtx.GetUserByID(r.Context(), link.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	// getting the link proves the address works
+	if !user.EmailVerifiedAt.Valid {
+		user, err = qtx.SetVerifiedEmail(r.Context(), database.SetVerifiedEmailParams{ID: user.ID, Email: user.Email})
+		if err != nil {
+			log.Printf("Error verifying email: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "Error logging in")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+
+	mfaRequired, err := cfg.mfaEnabled(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking two-factor status: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if mfaRequired {
+		cfg.startMFAChallenge(w, r, user.ID, magicLinkExpiry)
+		return
+	}
+	cfg.completeLogin(w, r, user, magicLinkExpiry)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckMagicLink(t *testing.T) {
+	now := time.Now()
+	link := database.MagicLink{ExpiresAt: now.Add(time.Minute)}
+	assert.NoError(t, checkMagicLink(link, now))
+
+	used := link
+	used.UsedAt = sql.NullTime{Time: now.Add(-time.Second), Valid: true}
+	assert.ErrorIs(t, checkMagicLink(used, now), errMagicLinkInvalid)
+
+	assert.ErrorIs(t, checkMagicLink(link, now.Add(time.Minute)), errMagicLinkInvalid)
+}
+
+func newMagicLinkUser() database.User {
+	return database.User{
+		ID:              uuid.New(),
+		CreatedAt:       fakeNow,
+		UpdatedAt:       fakeNow,
+		Email:           "user@example.com",
+		EmailVerifiedAt: sql.NullTime{Time: fakeNow, Valid: true},
+		Role:            "user",
+	}
+}
+
+func TestRequestMagicLinkRateLimit(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	db.on("GetUserByEmail", newMagicLinkUser())
+	db.on("CountRecentMagicLinks", int64(maxMagicLinks))
+
+	w := httptest.NewRecorder()
+	cfg.RequestMagicLink(w, httptest.NewRequest(http.MethodPost, "/api/login/magic-link", strings.NewReader(`{"email":"user@example.com"}`)))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Empty(t, db.called("CreateMagicLink"))
+	assert.Empty(t, db.called("ExpireMagicLinks"))
+}
+
+func TestLoginMagicLinkUsesLinkOnce(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	user := newMagicLinkUser()
+	link := database.MagicLink{ID: uuid.New(), UserID: user.ID, ExpiresAt: fakeNow.Add(time.Minute), CreatedAt: fakeNow}
+	db.on("GetMagicLinkForUpdate", link)
+	db.on("GetUserByID", user)
+	db.on("CreateSession", database.Session{ID: uuid.New(), UserID: user.ID, CreatedAt: fakeNow, LastUsedAt: fakeNow, ExpiresAt: fakeNow.Add(time.Hour)})
+	db.on("CreateRefreshToken", database.RefreshToken{UserID: user.ID, ExpiresAt: fakeNow.Add(time.Hour), FamilyID: uuid.New()})
+
+	w := httptest.NewRecorder()
+	cfg.LoginMagicLink(w, httptest.NewRequest(http.MethodPost, "/api/login/magic-link/verify", strings.NewReader(`{"token":"abc"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, db.called("UseMagicLink"), 1)
+	assert.Len(t, db.called("CreateSession"), 1)
+
+	link.UsedAt = sql.NullTime{Time: fakeNow, Valid: true}
+	db.on("GetMagicLinkForUpdate", link)
+	w = httptest.NewRecorder()
+	cfg.LoginMagicLink(w, httptest.NewRequest(http.MethodPost, "/api/login/magic-link/verify", strings.NewReader(`{"token":"abc"}`)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Len(t, db.called("UseMagicLink"), 1)
+	assert.Len(t, db.called("CreateSession"), 1)
+}
+
+func TestLoginMagicLinkExpired(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	db.on("GetMagicLinkForUpdate", database.MagicLink{ID: uuid.New(), UserID: uuid.New(), ExpiresAt: fakeNow.Add(-time.Second), CreatedAt: fakeNow})
+
+	w := httptest.NewRecorder()
+	cfg.LoginMagicLink(w, httptest.NewRequest(http.MethodPost, "/api/login/magic-link/verify", strings.NewReader(`{"token":"abc"}`)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, db.called("UseMagicLink"))
+	assert.Empty(t, db.called("CreateSession"))
+}
+
+func TestLoginMagicLinkHandsOffToMFA(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	user := newMagicLinkUser()
+	db.on("GetMagicLinkForUpdate", database.MagicLink{ID: uuid.New(), UserID: user.ID, ExpiresAt: fakeNow.Add(time.Minute), CreatedAt: fakeNow})
+	db.on("GetUserByID", user)
+	db.on("GetUserTOTP", database.UserTotp{UserID: user.ID, ConfirmedAt: sql.NullTime{Time: fakeNow, Valid: true}, CreatedAt: fakeNow})
+
+	// the confirm page posts a form
+	r := httptest.NewRequest(http.MethodPost, "/api/login/magic-link/verify", strings.NewReader(url.Values{"token": {"abc"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	cfg.LoginMagicLink(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+		Token       string `json:"token"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.True(t, resp.MFARequired)
+	assert.NotEmpty(t, resp.MFAToken)
+	assert.Empty(t, resp.Token)
+	assert.Len(t, db.called("CreateMFAChallenge"), 1)
+	assert.Empty(t, db.called("CreateSession"))
+}
+
+func TestMagicLinkPageKeepsToken(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	w := httptest.NewRecorder()
+	cfg.MagicLinkPage(w, httptest.NewRequest(http.MethodGet, "/app/magic-link?token=%22%3E%3Cscript%3E", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `action="/api/login/magic-link/verify"`)
+	assert.NotContains(t, body, `"><script>`)
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Empty(t, db.calls)
+}
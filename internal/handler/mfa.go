@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/totp"
+	"github.com/google/uuid"
+)
+
+const (
+	// mfaChallengeTTL is how long a user has to enter their code after the
+	// password check passed
+	mfaChallengeTTL = 5 * time.Minute
+	// maxMFAAttempts is how many codes may be tried against one challenge
+	maxMFAAttempts    = 5
+	recoveryCodeCount = 10
+	totpIssuer        = "Chirpy"
+)
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// mfaEnabled reports whether userId has confirmed a TOTP authenticator
+func (cfg *ApiConfig) mfaEnabled(ctx context.Context, userId uuid.UUID) (bool, error) {
+	enrolment, err := cfg.DB.GetUserTOTP(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return enrolment.ConfirmedAt.Valid, nil
+}
+
+// startMFAChallenge responds to a correct password for a user with 2FA on.
+// Instead of tokens the client gets a challenge token to send back with a
+// code to POST /api/login/mfa. expiresIn is kept for the access token
+// issued then.
+func (cfg *ApiConfig) startMFAChallenge(w http.ResponseWriter, r *http.Request, userId uuid.UUID, expiresIn time.Duration) {
+	type respBody struct {
+		MFARequired bool      `json:"mfa_required"`
+		MFAToken    string    `json:"mfa_token"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	expiresAt := time.Now().Add(mfaChallengeTTL)
+	err = cfg.DB.CreateMFAChallenge(r.Context(), database.CreateMFAChallengeParams{
+		TokenHash:        cfg.hashToken(token),
+		UserID:           userId,
+		ExpiresInSeconds: int32(expiresIn),
+		ExpiresAt:        expiresAt,
+	})
+	if err != nil {
+		log.Printf("Error creating MFA challenge: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, respBody{MFARequired: true, MFAToken: token, ExpiresAt: expiresAt})
+}
+
+// normalizeRecoveryCode lets users type a recovery code in any case, with
+// or without the dash
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// generateRecoveryCodes returns n random codes formatted as xxxxx-xxxxx
+func generateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, burns a recovery
+// code for userId. A TOTP code is refused if its time step was already used.
+func (cfg *ApiConfig) verifySecondFactor(ctx context.Context, q *database.Queries, userId uuid.UUID, code, recoveryCode string) error {
+	if code != "" {
+		enrolment, err := q.GetUserTOTP(ctx, userId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidSecondFactor
+		}
+		if err != nil {
+			return err
+		}
+		step, err := totp.Validate(enrolment.Secret, code, time.Now())
+		if err != nil {
+			return errInvalidSecondFactor
+		}
+		used, err := q.UseTOTPStep(ctx, database.UseTOTPStepParams{Step: step, UserID: userId})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+	if recoveryCode != "" {
+		used, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userId,
+			CodeHash: cfg.hashToken(normalizeRecoveryCode(recoveryCode)),
+		})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+	return errInvalidSecondFactor
+}
+
+// EnrollTOTP handles POST /api/2fa/totp/enroll. It stores a new secret
+// that only takes effect once confirmed, so enrolling again before then
+// simply replaces it.
+func (cfg *ApiConfig) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	type respBody struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	user, err := cfg.DB.GetUserByID(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to enroll")
+		return
+	}
+	stored, err := cfg.DB.UpsertPendingTOTP(r.Context(), database.UpsertPendingTOTPParams{UserID: userId, Secret: secret})
+	if err != nil {
+		log.Printf("Error saving TOTP secret: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to enroll")
+		return
+	}
+	if stored == 0 {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, respBody{
+		Secret:     secret,
+		OtpauthURI: totp.URI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTP handles POST /api/2fa/totp/confirm. A valid code from the
+// authenticator turns 2FA on and returns the recovery codes, which are
+// only ever shown this once.
+func (cfg *ApiConfig) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	type respBody struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	enrolment, err := cfg.DB.GetUserTOTP(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "no two-factor enrolment in progress")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+	if enrolment.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if err := cfg.verifySecondFactor(r.Context(), qtx, userId, params.Code, ""); err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			respondWithError(w, http.StatusUnauthorized, "invalid code")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+	confirmed, err := qtx.ConfirmTOTP(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+	if confirmed == 0 {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled")
+		return
+	}
+
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(r.Context(), userId); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+	for _, code := range codes {
+		err := qtx.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   userId,
+			CodeHash: cfg.hashToken(normalizeRecoveryCode(code)),
+		})
+		if err != nil {
+			log.Printf("Error saving recovery code: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "failed to confirm")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to confirm")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, respBody{RecoveryCodes: codes})
+}
+
+// DisableTOTP handles DELETE /api/2fa/totp. It takes a current code or a
+// recovery code so a stolen access token alone can't turn 2FA off.
+func (cfg *ApiConfig) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	enabled, err := cfg.mfaEnabled(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+	if !enabled {
+		respondWithError(w, http.StatusNotFound, "two-factor authentication is not enabled")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if err := cfg.verifySecondFactor(r.Context(), qtx, userId, params.Code, params.RecoveryCode); err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			respondWithError(w, http.StatusUnauthorized, "invalid code")
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+	if err := qtx.DeleteUserTOTP(r.Context(), userId); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+	if err := qtx.DeleteRecoveryCodes(r.Context(), userId); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LoginMFA handles POST /api/login/mfa, the second step of logging in with
+// 2FA on: the challenge token from POST /api/login plus a TOTP code or a
+// recovery code is exchanged for access and refresh tokens
+func (cfg *ApiConfig) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// counting the attempt is committed on its own so failures add up
+	challenge, err := cfg.DB.AttemptMFAChallenge(r.Context(), cfg.hashToken(params.MFAToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired mfa token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if challenge.Attempts > maxMFAAttempts {
+		respondWithError(w, http.StatusUnauthorized, "too many attempts, log in again")
+		return
+	}
+	user, err := cfg.DB.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	// wrong codes count towards the account's lockout like wrong passwords,
+	// so logging in again for a fresh challenge doesn't buy more guesses
+	if !cfg.checkLoginLockout(w, r, user.Email) {
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	if err := cfg.verifySecondFactor(r.Context(), qtx, challenge.UserID, params.Code, params.RecoveryCode); err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			cfg.recordLoginFailure(w, r, user.Email)
+			respondWithError(w, http.StatusUnauthorized, "invalid code")
+			return
+		}
+		log.Printf("Error verifying second factor: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	used, err := qtx.UseMFAChallenge(r.Context(), challenge.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if used == 0 {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired mfa token")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	cfg.recordLoginSuccess(r, user.Email)
+
+	cfg.completeLogin(w, r, user, time.Duration(challenge.ExpiresInSeconds))
+}
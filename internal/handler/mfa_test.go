@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := generateRecoveryCodes(recoveryCodeCount)
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, format, code)
+		assert.False(t, seen[code], "duplicate code %s", code)
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	assert.Equal(t, "abcde23456", normalizeRecoveryCode("abcde-23456"))
+	assert.Equal(t, "abcde23456", normalizeRecoveryCode("ABCDE 23456"))
+	assert.Equal(t, "abcde23456", normalizeRecoveryCode("abcde23456"))
+}
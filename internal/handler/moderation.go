@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+const defaultFlagLimit = 50
+
+// moderator returns the configured Moderator, falling back to the default
+// 140 character limit and word list
+func (cfg *ApiConfig) moderator() *moderation.Moderator {
+	cfg.moderatorOnce.Do(func() {
+		if cfg.Moderator == nil {
+			cfg.Moderator = moderation.Default()
+		}
+	})
+	return cfg.Moderator
+}
+
+// moderateChirp runs body through the moderation chain. The error is safe
+// to show to the client.
+func (cfg *ApiConfig) moderateChirp(body string) (moderation.Result, error) {
+	result, err := cfg.moderator().Moderate(body)
+	switch {
+	case errors.Is(err, moderation.ErrTooLong):
+		return result, errors.New("Chirp is too long")
+	case errors.Is(err, moderation.ErrRejected):
+		return result, errors.New("Chirp contains content that is not allowed")
+	}
+	return result, err
+}
+
+// saveModerationFlags queues every flag rule result matched for review
+func saveModerationFlags(ctx context.Context, q *database.Queries, chirpID uuid.UUID, result moderation.Result) error {
+	for _, m := range result.Matches {
+		if m.Rule.Action != moderation.ActionFlag {
+			continue
+		}
+		err := q.CreateModerationFlag(ctx, database.CreateModerationFlagParams{
+			ChirpID:  chirpID,
+			RuleKind: m.Rule.Kind,
+			Pattern:  m.Rule.Pattern,
+			Matched:  m.Text,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReloadModerationRules rebuilds the moderator from the word list file, if
+// any, followed by the rules stored in the database
+func (cfg *ApiConfig) ReloadModerationRules(ctx context.Context) error {
+	var rules []moderation.Rule
+	if cfg.ModerationWordList != "" {
+		fileRules, err := moderation.LoadWordListFile(cfg.ModerationWordList, moderation.ActionMask)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, fileRules...)
+	}
+	dbRules, err := cfg.DB.ListModerationRules(ctx)
+	if err != nil {
+		return err
+	}
+	for _, rule := range dbRules {
+		rules = append(rules, moderationRule(rule))
+	}
+	return cfg.moderator().SetRules(rules)
+}
+
+func moderationRule(rule database.ModerationRule) moderation.Rule {
+	return moderation.Rule{
+		ID:      rule.ID.String(),
+		Kind:    rule.Kind,
+		Pattern: rule.Pattern,
+		Action:  moderation.Action(rule.Action),
+	}
+}
+
+type moderationRuleResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Kind      string    `json:"kind"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newModerationRuleResponse(rule database.ModerationRule) moderationRuleResponse {
+	return moderationRuleResponse{
+		ID:        rule.ID,
+		Kind:      rule.Kind,
+		Pattern:   rule.Pattern,
+		Action:    rule.Action,
+		CreatedAt: rule.CreatedAt,
+	}
+}
+
+// GetModerationRules handles GET /admin/moderation/rules
+func (cfg *ApiConfig) GetModerationRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := cfg.DB.ListModerationRules(r.Context())
+	if err != nil {
+		log.Printf("Error listing moderation rules: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting rules")
+		return
+	}
+	resp := make([]moderationRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		resp = append(resp, newModerationRuleResponse(rule))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// CreateModerationRule handles POST /admin/moderation/rules. The body is
+// {"kind": "word"|"regex", "pattern": "...", "action": "mask"|"reject"|"flag"}
+// and the rule applies to chirps as soon as it is saved.
+func (cfg *ApiConfig) CreateModerationRule(w http.ResponseWriter, r *http.Request) {
+	var params struct {
+		Kind    string `json:"kind"`
+		Pattern string `json:"pattern"`
+		Action  string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	err := moderation.ValidateRule(moderation.Rule{
+		Kind:    params.Kind,
+		Pattern: params.Pattern,
+		Action:  moderation.Action(params.Action),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule, err := cfg.DB.CreateModerationRule(r.Context(), database.CreateModerationRuleParams{
+		Kind:    params.Kind,
+		Pattern: params.Pattern,
+		Action:  params.Action,
+	})
+	if err != nil {
+		log.Printf("Error creating moderation rule: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred creating rule")
+		return
+	}
+	if err := cfg.ReloadModerationRules(r.Context()); err != nil {
+		log.Printf("Error reloading moderation rules: %v", err)
+	}
+	respondWithJSON(w, http.StatusCreated, newModerationRuleResponse(rule))
+}
+
+// DeleteModerationRule handles DELETE /admin/moderation/rules/{id}
+func (cfg *ApiConfig) DeleteModerationRule(w http.ResponseWriter, r *http.Request) {
+	ruleId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+	deleted, err := cfg.DB.DeleteModerationRule(r.Context(), ruleId)
+	if err != nil {
+		log.Printf("Error deleting moderation rule: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred deleting rule")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "rule not found")
+		return
+	}
+	if err := cfg.ReloadModerationRules(r.Context()); err != nil {
+		log.Printf("Error reloading moderation rules: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetModerationFlags handles GET /admin/moderation/flags, listing flagged
+// chirps that haven't been reviewed yet, oldest first
+func (cfg *ApiConfig) GetModerationFlags(w http.ResponseWriter, r *http.Request) {
+	limit := defaultFlagLimit
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed < 1 {
+			respondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, maxPageLimit)
+	}
+
+	flags, err := cfg.DB.ListOpenModerationFlags(r.Context(), int32(limit))
+	if err != nil {
+		log.Printf("Error listing moderation flags: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting flags")
+		return
+	}
+
+	type flagResponse struct {
+		ID        uuid.UUID `json:"id"`
+		ChirpID   uuid.UUID `json:"chirp_id"`
+		RuleKind  string    `json:"rule_kind"`
+		Pattern   string    `json:"pattern"`
+		Matched   string    `json:"matched"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	resp := make([]flagResponse, 0, len(flags))
+	for _, flag := range flags {
+		resp = append(resp, flagResponse{
+			ID:        flag.ID,
+			ChirpID:   flag.ChirpID,
+			RuleKind:  flag.RuleKind,
+			Pattern:   flag.Pattern,
+			Matched:   flag.Matched,
+			CreatedAt: flag.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// ResolveModerationFlag handles POST /admin/moderation/flags/{id}/resolve
+func (cfg *ApiConfig) ResolveModerationFlag(w http.ResponseWriter, r *http.Request) {
+	flagId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid flag id")
+		return
+	}
+	resolved, err := cfg.DB.ResolveModerationFlag(r.Context(), flagId)
+	if err != nil {
+		log.Printf("Error resolving moderation flag: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred resolving flag")
+		return
+	}
+	if resolved == 0 {
+		respondWithError(w, http.StatusNotFound, "open flag not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultModeratorIsShared(t *testing.T) {
+	cfg := &ApiConfig{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cfg.moderateChirp("hello")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.NotNil(t, cfg.Moderator)
+	assert.Same(t, cfg.Moderator, cfg.moderator())
+}
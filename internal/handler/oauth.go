@@ -0,0 +1,513 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+)
+
+const (
+	// oauthCodeTTL is how long an authorization code can be exchanged
+	oauthCodeTTL = 5 * time.Minute
+	// oauthAccessTokenTTL is how long an access token issued to a client lasts
+	oauthAccessTokenTTL = time.Hour
+	// oauthRefreshTokenTTL is how long a client's refresh token lasts unused
+	oauthRefreshTokenTTL = 30 * 24 * time.Hour
+	// maxOAuthFormBytes bounds the form bodies of the OAuth endpoints
+	maxOAuthFormBytes = 64 << 10
+)
+
+var (
+	errOAuthAccessToken  = errors.New("OAuth access tokens can't be used here")
+	errRevokedOAuthGrant = errors.New("access to this account has been revoked")
+	errInvalidClient     = errors.New("invalid client credentials")
+)
+
+// oauthError is an error response defined by RFC 6749 section 5.2
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// respondWithOAuthError sends an OAuth error from the token, revocation or
+// introspection endpoints
+func respondWithOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, status, oauthError{Code: code, Description: description})
+}
+
+// checkOAuthAccessToken verifies that an access token issued to a client
+// was granted scope and that the user hasn't revoked the client since
+func (cfg *ApiConfig) checkOAuthAccessToken(ctx context.Context, claims auth.AccessClaims, scope string) error {
+	if !auth.HasScope(claims.Scopes, scope) {
+		return fmt.Errorf("%w %s", errInsufficientScope, scope)
+	}
+	return cfg.checkOAuthGrant(ctx, claims)
+}
+
+// checkOAuthGrant reports whether the grant an access token was issued
+// under is still in force
+func (cfg *ApiConfig) checkOAuthGrant(ctx context.Context, claims auth.AccessClaims) error {
+	grant, err := cfg.DB.GetOAuthGrant(ctx, claims.SessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errRevokedOAuthGrant
+	}
+	if err != nil {
+		return err
+	}
+	if grant.RevokedAt.Valid || grant.UserID != claims.UserID {
+		return errRevokedOAuthGrant
+	}
+	return nil
+}
+
+// authenticateOAuthClient identifies the client calling the token,
+// revocation or introspection endpoint, from HTTP Basic credentials or
+// client_id and client_secret form fields. Public clients send no secret.
+func (cfg *ApiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientId, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 section 2.3.1 form-encodes both before Basic encoding
+		var err error
+		if clientId, err = url.QueryUnescape(clientId); err != nil {
+			return database.OauthClient{}, errInvalidClient
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return database.OauthClient{}, errInvalidClient
+		}
+	} else {
+		clientId, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientId == "" {
+		return database.OauthClient{}, errInvalidClient
+	}
+	client, err := cfg.DB.GetOAuthClient(r.Context(), clientId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthClient{}, errInvalidClient
+	}
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if client.ClientSecretHash.Valid != (secret != "") {
+		return database.OauthClient{}, errInvalidClient
+	}
+	if client.ClientSecretHash.Valid && !hmac.Equal([]byte(cfg.hashToken(secret)), []byte(client.ClientSecretHash.String)) {
+		return database.OauthClient{}, errInvalidClient
+	}
+	return client, nil
+}
+
+// parseOAuthForm reads a form-encoded OAuth request body and the calling
+// client, answering with an OAuth error and returning false when either is
+// bad
+func (cfg *ApiConfig) parseOAuthForm(w http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxOAuthFormBytes)
+	if err := r.ParseForm(); err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid form body")
+		return database.OauthClient{}, false
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if errors.Is(err, errInvalidClient) {
+		if _, _, basic := r.BasicAuth(); basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		}
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return database.OauthClient{}, false
+	}
+	if err != nil {
+		log.Printf("Error authenticating OAuth client: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return database.OauthClient{}, false
+	}
+	return client, true
+}
+
+// oauthTokenResponse is a successful token response, RFC 6749 section 5.1
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// issueOAuthTokens stores a refresh token for grant carrying refreshScopes
+// and signs an access token carrying accessScopes, which may be narrower
+func (cfg *ApiConfig) issueOAuthTokens(ctx context.Context, q *database.Queries, client database.OauthClient,
+	grant database.OauthGrant, accessScopes, refreshScopes []string) (oauthTokenResponse, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	err = q.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{
+		TokenHash: cfg.hashToken(refreshToken),
+		GrantID:   grant.ID,
+		Scopes:    refreshScopes,
+		ExpiresAt: time.Now().Add(oauthRefreshTokenTTL),
+	})
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	accessToken, err := cfg.signAccessToken(auth.AccessClaims{
+		UserID:    grant.UserID,
+		SessionID: grant.ID,
+		ClientID:  client.ClientID,
+		Scopes:    accessScopes,
+	}, oauthAccessTokenTTL)
+	if err != nil {
+		return oauthTokenResponse{}, err
+	}
+	return oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenTTL / time.Second),
+		RefreshToken: refreshToken,
+		Scope:        auth.FormatScope(accessScopes),
+	}, nil
+}
+
+// revokeOAuthGrant revokes a grant along with its refresh tokens
+func revokeOAuthGrant(ctx context.Context, q *database.Queries, grant database.OauthGrant) error {
+	if err := q.RevokeOAuthGrantByID(ctx, grant.ID); err != nil {
+		return err
+	}
+	return q.RevokeOAuthRefreshTokensForGrant(ctx, grant.ID)
+}
+
+// OAuthToken handles POST /oauth/token, the token endpoint. It exchanges an
+// authorization code and its PKCE verifier, or a refresh token, for a new
+// access and refresh token pair. Refresh tokens rotate on every use, and a
+// code or refresh token used twice revokes the whole grant, since one of
+// the two callers must have stolen it.
+func (cfg *ApiConfig) OAuthToken(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.parseOAuthForm(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	var resp oauthTokenResponse
+	var oauthErr *oauthError
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		resp, oauthErr, err = cfg.exchangeAuthorizationCode(r, qtx, client)
+	case "refresh_token":
+		resp, oauthErr, err = cfg.exchangeOAuthRefreshToken(r, qtx, client)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+	if err != nil {
+		log.Printf("Error issuing OAuth tokens: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	// a replayed code or refresh token revokes its grant, which must stick
+	// even though the request fails
+	if err := tx.Commit(); err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if oauthErr != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, oauthErr.Code, oauthErr.Description)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// exchangeAuthorizationCode is the authorization_code grant of OAuthToken
+func (cfg *ApiConfig) exchangeAuthorizationCode(r *http.Request, q *database.Queries, client database.OauthClient) (oauthTokenResponse, *oauthError, error) {
+	invalid := &oauthError{Code: "invalid_grant", Description: "invalid or expired authorization code"}
+	code, err := q.GetOAuthAuthorizationCodeForUpdate(r.Context(), cfg.hashToken(r.PostForm.Get("code")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return oauthTokenResponse{}, invalid, nil
+	}
+	if err != nil {
+		return oauthTokenResponse{}, nil, err
+	}
+	grant, err := q.GetOAuthGrant(r.Context(), code.GrantID)
+	if err != nil {
+		return oauthTokenResponse{}, nil, err
+	}
+	if grant.ClientID != client.ID {
+		return oauthTokenResponse{}, invalid, nil
+	}
+	if code.UsedAt.Valid {
+		log.Printf("Authorization code reused, revoking grant %s", grant.ID)
+		return oauthTokenResponse{}, invalid, revokeOAuthGrant(r.Context(), q, grant)
+	}
+	if grant.RevokedAt.Valid || time.Now().After(code.ExpiresAt) {
+		return oauthTokenResponse{}, invalid, nil
+	}
+	if r.PostForm.Get("redirect_uri") != code.RedirectUri {
+		return oauthTokenResponse{}, &oauthError{Code: "invalid_grant", Description: "redirect_uri does not match"}, nil
+	}
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		return oauthTokenResponse{}, &oauthError{Code: "invalid_grant", Description: "code_verifier does not match"}, nil
+	}
+	if oauthErr, err := checkGrantUser(r.Context(), q, grant); oauthErr != nil || err != nil {
+		return oauthTokenResponse{}, oauthErr, err
+	}
+	if err := q.UseOAuthAuthorizationCode(r.Context(), code.ID); err != nil {
+		return oauthTokenResponse{}, nil, err
+	}
+	resp, err := cfg.issueOAuthTokens(r.Context(), q, client, grant, code.Scopes, code.Scopes)
+	return resp, nil, err
+}
+
+// checkGrantUser refuses new tokens for a grant whose user is suspended
+func checkGrantUser(ctx context.Context, q *database.Queries, grant database.OauthGrant) (*oauthError, error) {
+	user, err := q.GetUserByID(ctx, grant.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if suspended(user, time.Now()) {
+		return &oauthError{Code: "invalid_grant", Description: "the user's account is suspended"}, nil
+	}
+	return nil, nil
+}
+
+// exchangeOAuthRefreshToken is the refresh_token grant of OAuthToken. A
+// scope parameter narrows the new access token; the new refresh token keeps
+// the scopes of the old one.
+func (cfg *ApiConfig) exchangeOAuthRefreshToken(r *http.Request, q *database.Queries, client database.OauthClient) (oauthTokenResponse, *oauthError, error) {
+	invalid := &oauthError{Code: "invalid_grant", Description: "invalid or expired refresh token"}
+	token, err := q.GetOAuthRefreshTokenForUpdate(r.Context(), cfg.hashToken(r.PostForm.Get("refresh_token")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return oauthTokenResponse{}, invalid, nil
+	}
+	if err != nil {
+		return oauthTokenResponse{}, nil, err
+	}
+	grant, err := q.GetOAuthGrant(r.Context(), token.GrantID)
+	if err != nil {
+		return oauthTokenResponse{}, nil, err
+	}
+	if grant.ClientID != client.ID {
+		return oauthTokenResponse{}, invalid, nil
+	}
+	if token.RevokedAt.Valid {
+		if !grant.RevokedAt.Valid {
+			log.Printf("OAuth refresh token reused, revoking grant %s", grant.ID)
+		}
+		return oauthTokenResponse{}, invalid, revokeOAuthGrant(r.Context(), q, grant)
+	}
+	if grant.RevokedAt.Valid || time.Now().After(token.ExpiresAt) {
+		return oauthTokenResponse{}, invalid, nil
+	}
+	if oauthErr, err := checkGrantUser(r.Context(), q, grant); oauthErr != nil || err != nil {
+		return oauthTokenResponse{}, oauthErr, err
+	}
+
+	scopes := token.Scopes
+	if requested := auth.ParseScope(r.PostForm.Get("scope")); len(requested) > 0 {
+		for _, scope := range requested {
+			if !auth.HasScope(token.Scopes, scope) {
+				return oauthTokenResponse{}, &oauthError{Code: "invalid_scope", Description: fmt.Sprintf("scope %q was not granted", scope)}, nil
+			}
+		}
+		scopes = requested
+	}
+	if err := q.RevokeOAuthRefreshToken(r.Context(), token.ID); err != nil {
+		return oauthTokenResponse{}, nil, err
+	}
+	resp, err := cfg.issueOAuthTokens(r.Context(), q, client, grant, scopes, token.Scopes)
+	return resp, nil, err
+}
+
+// OAuthRevoke handles POST /oauth/revoke (RFC 7009). Revoking either an
+// access or a refresh token ends the user's authorization of the client,
+// so every token issued under it stops working. Unknown tokens, and tokens
+// of other clients, are ignored.
+func (cfg *ApiConfig) OAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	client, ok := cfg.parseOAuthForm(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	grant, found, err := cfg.lookupOAuthTokenGrant(r.Context(), qtx, client, token)
+	if err != nil {
+		log.Printf("Error looking up OAuth token: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if found {
+		if err := revokeOAuthGrant(r.Context(), qtx, grant); err != nil {
+			log.Printf("Error revoking OAuth grant: %v", err)
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// lookupOAuthTokenGrant finds the grant an access or refresh token issued
+// to client belongs to
+func (cfg *ApiConfig) lookupOAuthTokenGrant(ctx context.Context, q *database.Queries, client database.OauthClient, token string) (database.OauthGrant, bool, error) {
+	if claims, err := cfg.parseAccessToken(token); err == nil {
+		if claims.ClientID != client.ClientID {
+			return database.OauthGrant{}, false, nil
+		}
+		grant, err := q.GetOAuthGrant(ctx, claims.SessionID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.OauthGrant{}, false, nil
+		}
+		return grant, err == nil, err
+	}
+	refresh, err := q.GetOAuthRefreshTokenForUpdate(ctx, cfg.hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthGrant{}, false, nil
+	}
+	if err != nil {
+		return database.OauthGrant{}, false, err
+	}
+	grant, err := q.GetOAuthGrant(ctx, refresh.GrantID)
+	if err != nil {
+		return database.OauthGrant{}, false, err
+	}
+	return grant, grant.ClientID == client.ID, nil
+}
+
+// OAuthIntrospect handles POST /oauth/introspect (RFC 7662), telling a
+// client whether one of its tokens is active and what it grants. Tokens of
+// other clients are reported inactive.
+func (cfg *ApiConfig) OAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	type respBody struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		IssuedAt  int64  `json:"iat,omitempty"`
+	}
+	client, ok := cfg.parseOAuthForm(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostForm.Get("token")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if claims, err := cfg.parseAccessToken(token); err == nil {
+		if claims.ClientID != client.ClientID {
+			respondWithJSON(w, http.StatusOK, respBody{})
+			return
+		}
+		err := cfg.checkOAuthGrant(r.Context(), claims)
+		if errors.Is(err, errRevokedOAuthGrant) {
+			respondWithJSON(w, http.StatusOK, respBody{})
+			return
+		}
+		if err != nil {
+			log.Printf("Error checking OAuth grant: %v", err)
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, respBody{
+			Active:    true,
+			Scope:     auth.FormatScope(claims.Scopes),
+			ClientID:  claims.ClientID,
+			Subject:   claims.UserID.String(),
+			TokenType: "Bearer",
+			ExpiresAt: claims.ExpiresAt.Unix(),
+			IssuedAt:  claims.IssuedAt.Unix(),
+		})
+		return
+	}
+
+	refresh, err := cfg.DB.GetOAuthRefreshTokenForUpdate(r.Context(), cfg.hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithJSON(w, http.StatusOK, respBody{})
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up OAuth refresh token: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	grant, err := cfg.DB.GetOAuthGrant(r.Context(), refresh.GrantID)
+	if err != nil {
+		log.Printf("Error looking up OAuth grant: %v", err)
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if grant.ClientID != client.ID || grant.RevokedAt.Valid || refresh.RevokedAt.Valid || time.Now().After(refresh.ExpiresAt) {
+		respondWithJSON(w, http.StatusOK, respBody{})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, respBody{
+		Active:    true,
+		Scope:     auth.FormatScope(refresh.Scopes),
+		ClientID:  client.ClientID,
+		Subject:   grant.UserID.String(),
+		TokenType: "refresh_token",
+		ExpiresAt: refresh.ExpiresAt.Unix(),
+		IssuedAt:  refresh.CreatedAt.Unix(),
+	})
+}
+
+// OAuthMetadata handles GET /.well-known/oauth-authorization-server
+// (RFC 8414), describing the endpoints and options clients can use
+func (cfg *ApiConfig) OAuthMetadata(w http.ResponseWriter, r *http.Request) {
+	type respBody struct {
+		Issuer                            string   `json:"issuer"`
+		AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+		TokenEndpoint                     string   `json:"token_endpoint"`
+		RevocationEndpoint                string   `json:"revocation_endpoint"`
+		IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+		JWKSURI                           string   `json:"jwks_uri"`
+		ScopesSupported                   []string `json:"scopes_supported"`
+		ResponseTypesSupported            []string `json:"response_types_supported"`
+		GrantTypesSupported               []string `json:"grant_types_supported"`
+		CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+		TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	}
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	respondWithJSON(w, http.StatusOK, respBody{
+		Issuer:                            cfg.PublicURL,
+		AuthorizationEndpoint:             cfg.PublicURL + "/oauth/authorize",
+		TokenEndpoint:                     cfg.PublicURL + "/oauth/token",
+		RevocationEndpoint:                cfg.PublicURL + "/oauth/revoke",
+		IntrospectionEndpoint:             cfg.PublicURL + "/oauth/introspect",
+		JWKSURI:                           cfg.PublicURL + "/.well-known/jwks.json",
+		ScopesSupported:                   auth.Scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+	})
+}
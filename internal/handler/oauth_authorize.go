@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// scopeDescriptions says on the consent screen what each scope lets an app do
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:     "See chirps as you",
+	auth.ScopeChirpsWrite:    "Post, edit and delete chirps and rechirps as you",
+	auth.ScopeMediaWrite:     "Upload images as you",
+	auth.ScopeFollowsWrite:   "Follow and unfollow people as you",
+	auth.ScopeReactionsWrite: "React to chirps as you",
+	auth.ScopeProfileWrite:   "Resend your email confirmation",
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Authorize {{.Client}} - Chirpy</title>
+<style>
+body { font-family: sans-serif; max-width: 28rem; margin: 3rem auto; padding: 0 1rem; }
+label { display: block; margin-top: 0.75rem; }
+input[type=email], input[type=password], input[type=text] { width: 100%; padding: 0.4rem; box-sizing: border-box; }
+.error { color: #b00020; }
+.buttons { margin-top: 1.25rem; display: flex; gap: 0.5rem; }
+</style>
+</head>
+<body>
+{{if .Request}}
+<h1>Authorize {{.Client}}</h1>
+<p><strong>{{.Client}}</strong> wants to use your Chirpy account to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+<p>It will never see your password. You can take back its access at any time from your authorized apps.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth/authorize">
+{{range $name, $value := .Request}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label>
+<label>Password <input type="password" name="password" autocomplete="current-password"></label>
+<label>Two-factor code, if you use one <input type="text" name="mfa_code" autocomplete="one-time-code"></label>
+<div class="buttons">
+<button type="submit" name="decision" value="approve">Allow</button>
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+</div>
+</form>
+{{else}}
+<h1>Can't authorize this app</h1>
+<p class="error">{{.Error}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+type consentPage struct {
+	Client  string
+	Scopes  []string
+	Request map[string]string
+	Email   string
+	Error   string
+}
+
+// renderConsent writes the consent page, or an error page when page has
+// no request. It can't be framed, so other sites can't trick a user into
+// approving.
+func renderConsent(w http.ResponseWriter, status int, page consentPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.WriteHeader(status)
+	if err := consentTemplate.Execute(w, page); err != nil {
+		log.Printf("Error rendering consent page: %v", err)
+	}
+}
+
+// authorizeRequest is a validated request to /oauth/authorize
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	CodeChallenge string
+}
+
+// params returns the request as the hidden fields the consent form posts
+// back
+func (req authorizeRequest) params() map[string]string {
+	return map[string]string{
+		"response_type":         "code",
+		"client_id":             req.Client.ClientID,
+		"redirect_uri":          req.RedirectURI,
+		"scope":                 auth.FormatScope(req.Scopes),
+		"state":                 req.State,
+		"code_challenge":        req.CodeChallenge,
+		"code_challenge_method": "S256",
+	}
+}
+
+// redirect sends the user agent back to the client with params, and the
+// state the client sent
+func (req authorizeRequest) redirect(w http.ResponseWriter, r *http.Request, params url.Values) {
+	u, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		renderConsent(w, http.StatusBadRequest, consentPage{Error: "The app's redirect URI is invalid."})
+		return
+	}
+	query := u.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// redirectError sends an OAuth error back to the client
+func (req authorizeRequest) redirectError(w http.ResponseWriter, r *http.Request, code, description string) {
+	req.redirect(w, r, url.Values{"error": {code}, "error_description": {description}})
+}
+
+// parseAuthorizeRequest validates the parameters of an authorization
+// request. Until the client and redirect URI are known good, problems are
+// shown to the user rather than redirected, so the endpoint can't be used
+// to bounce users to arbitrary sites; later problems go back to the client.
+func (cfg *ApiConfig) parseAuthorizeRequest(w http.ResponseWriter, r *http.Request) (authorizeRequest, bool) {
+	client, err := cfg.DB.GetOAuthClient(r.Context(), r.Form.Get("client_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		renderConsent(w, http.StatusBadRequest, consentPage{Error: "The app asking for access isn't registered with Chirpy."})
+		return authorizeRequest{}, false
+	}
+	if err != nil {
+		log.Printf("Error looking up OAuth client: %v", err)
+		renderConsent(w, http.StatusInternalServerError, consentPage{Error: "Something went wrong, please try again."})
+		return authorizeRequest{}, false
+	}
+	req := authorizeRequest{Client: client, RedirectURI: r.Form.Get("redirect_uri"), State: r.Form.Get("state")}
+	if req.RedirectURI == "" && len(client.RedirectUris) == 1 {
+		req.RedirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		renderConsent(w, http.StatusBadRequest, consentPage{Error: "The app's redirect URI doesn't match any it registered."})
+		return authorizeRequest{}, false
+	}
+
+	if r.Form.Get("response_type") != "code" {
+		req.redirectError(w, r, "unsupported_response_type", "only the code response type is supported")
+		return authorizeRequest{}, false
+	}
+	req.CodeChallenge = r.Form.Get("code_challenge")
+	if req.CodeChallenge == "" || r.Form.Get("code_challenge_method") != "S256" {
+		req.redirectError(w, r, "invalid_request", "PKCE with the S256 method is required")
+		return authorizeRequest{}, false
+	}
+	req.Scopes, err = auth.ValidateScopes(auth.ParseScope(r.Form.Get("scope")))
+	if err != nil {
+		req.redirectError(w, r, "invalid_scope", err.Error())
+		return authorizeRequest{}, false
+	}
+	return req, true
+}
+
+// consentPage returns the page asking the user to approve req
+func (req authorizeRequest) consentPage(email, message string) consentPage {
+	page := consentPage{Client: req.Client.Name, Request: req.params(), Email: email, Error: message}
+	for _, scope := range req.Scopes {
+		page.Scopes = append(page.Scopes, scopeDescriptions[scope])
+	}
+	return page
+}
+
+// OAuthAuthorize handles GET /oauth/authorize, the start of the
+// authorization code flow. It shows the user what the app is asking for
+// and lets them sign in to approve it.
+func (cfg *ApiConfig) OAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderConsent(w, http.StatusBadRequest, consentPage{Error: "The authorization request is malformed."})
+		return
+	}
+	req, ok := cfg.parseAuthorizeRequest(w, r)
+	if !ok {
+		return
+	}
+	renderConsent(w, http.StatusOK, req.consentPage("", ""))
+}
+
+// OAuthAuthorizeDecision handles POST /oauth/authorize, the consent form.
+// Approving needs the account's password, and its second factor when it
+// has one, and counts towards login lockout like LoginUser. The client gets
+// back a single-use code it can exchange at OAuthToken.
+func (cfg *ApiConfig) OAuthAuthorizeDecision(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxOAuthFormBytes)
+	if err := r.ParseForm(); err != nil {
+		renderConsent(w, http.StatusBadRequest, consentPage{Error: "The authorization request is malformed."})
+		return
+	}
+	req, ok := cfg.parseAuthorizeRequest(w, r)
+	if !ok {
+		return
+	}
+	if r.PostForm.Get("decision") != "approve" {
+		req.redirectError(w, r, "access_denied", "the user denied access")
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	if wait := cfg.loginLockoutWait(r, email); wait > 0 {
+		w.Header().Set("Retry-After", retryAfter(wait))
+		renderConsent(w, http.StatusTooManyRequests, req.consentPage(email, "Too many failed sign-in attempts, try again later."))
+		return
+	}
+	user, err := cfg.userByEmail(r.Context(), email)
+	if err == nil {
+		err = auth.CheckPasswordHash(user.HashedPassword, r.PostForm.Get("password"))
+	}
+	if err != nil {
+		cfg.recordLoginFailure(w, r, email)
+		renderConsent(w, http.StatusUnauthorized, req.consentPage(email, "Incorrect email or password."))
+		return
+	}
+
+	mfaRequired, err := cfg.mfaEnabled(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking two-factor status: %v", err)
+		renderConsent(w, http.StatusInternalServerError, req.consentPage(email, "Something went wrong, please try again."))
+		return
+	}
+	if mfaRequired {
+		code := r.PostForm.Get("mfa_code")
+		if code == "" {
+			renderConsent(w, http.StatusUnauthorized, req.consentPage(email, "Enter the code from your authenticator app or a recovery code."))
+			return
+		}
+		// the one field takes either kind of code
+		err := cfg.verifySecondFactor(r.Context(), cfg.DB, user.ID, code, "")
+		if errors.Is(err, errInvalidSecondFactor) {
+			err = cfg.verifySecondFactor(r.Context(), cfg.DB, user.ID, "", code)
+		}
+		if errors.Is(err, errInvalidSecondFactor) {
+			cfg.recordLoginFailure(w, r, email)
+			renderConsent(w, http.StatusUnauthorized, req.consentPage(email, "Incorrect two-factor code."))
+			return
+		}
+		if err != nil {
+			log.Printf("Error verifying second factor: %v", err)
+			renderConsent(w, http.StatusInternalServerError, req.consentPage(email, "Something went wrong, please try again."))
+			return
+		}
+	}
+	cfg.recordLoginSuccess(r, email)
+	cfg.rehashPassword(r, user, r.PostForm.Get("password"))
+	if suspended(user, time.Now()) {
+		renderConsent(w, http.StatusForbidden, req.consentPage(email, "This account is suspended."))
+		return
+	}
+
+	code, err := cfg.createAuthorizationCode(r.Context(), req, user.ID)
+	if err != nil {
+		log.Printf("Error creating authorization code: %v", err)
+		req.redirectError(w, r, "server_error", "failed to authorize")
+		return
+	}
+	req.redirect(w, r, url.Values{"code": {code}})
+}
+
+// createAuthorizationCode records userId's consent to req and returns a
+// code for it
+func (cfg *ApiConfig) createAuthorizationCode(ctx context.Context, req authorizeRequest, userId uuid.UUID) (string, error) {
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	grant, err := qtx.UpsertOAuthGrant(ctx, database.UpsertOAuthGrantParams{
+		UserID:   userId,
+		ClientID: req.Client.ID,
+		Scopes:   req.Scopes,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = qtx.CreateOAuthAuthorizationCode(ctx, database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      cfg.hashToken(code),
+		GrantID:       grant.ID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        req.Scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, tx.Commit()
+}
@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxClientNameLength = 100
+	maxRedirectURIs     = 10
+)
+
+// validateRedirectURI checks a redirect URI a client registers. It must be
+// absolute and without a fragment, and use https, http on a loopback
+// address for apps running on the user's machine, or a private-use scheme
+// such as com.example.app for native apps (RFC 8252).
+func validateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("redirect URI %q must be an absolute URL", raw)
+	}
+	if u.Fragment != "" || strings.Contains(raw, "#") {
+		return fmt.Errorf("redirect URI %q must not have a fragment", raw)
+	}
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return fmt.Errorf("redirect URI %q has no host", raw)
+		}
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("redirect URI %q must use https unless it is a loopback address", raw)
+		}
+	default:
+		if !strings.Contains(u.Scheme, ".") {
+			return fmt.Errorf("redirect URI %q must use https or a reverse domain name scheme", raw)
+		}
+	}
+	return nil
+}
+
+type oauthClientResponse struct {
+	ID           uuid.UUID `json:"id"`
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+	// ClientSecret is only ever returned when the client is registered
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func newOAuthClientResponse(client database.OauthClient) oauthClientResponse {
+	return oauthClientResponse{
+		ID:           client.ID,
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Confidential: client.ClientSecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+// CreateOAuthClient handles POST /api/oauth/clients, registering an app
+// that can ask users for access. Confidential clients, which run on a
+// server, also get a secret; public ones rely on PKCE alone.
+func (cfg *ApiConfig) CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || len(params.Name) > maxClientNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("name must be 1 to %d characters", maxClientNameLength))
+		return
+	}
+	if len(params.RedirectURIs) == 0 || len(params.RedirectURIs) > maxRedirectURIs {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("redirect_uris must list 1 to %d URIs", maxRedirectURIs))
+		return
+	}
+	for _, uri := range params.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	clientId, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to register client")
+		return
+	}
+	secret, secretHash := "", sql.NullString{}
+	if params.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to register client")
+			return
+		}
+		secretHash = sql.NullString{String: cfg.hashToken(secret), Valid: true}
+	}
+	client, err := cfg.DB.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ClientID:         clientId[:32],
+		ClientSecretHash: secretHash,
+		Name:             params.Name,
+		RedirectUris:     params.RedirectURIs,
+		OwnerID:          userId,
+	})
+	if err != nil {
+		log.Printf("Error creating OAuth client: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to register client")
+		return
+	}
+	resp := newOAuthClientResponse(client)
+	resp.ClientSecret = secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// GetOAuthClients handles GET /api/oauth/clients, listing the apps the
+// caller registered, newest first
+func (cfg *ApiConfig) GetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	clients, err := cfg.DB.ListOAuthClients(r.Context(), userId)
+	if err != nil {
+		log.Printf("Error listing OAuth clients: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting clients")
+		return
+	}
+	resp := make([]oauthClientResponse, 0, len(clients))
+	for _, client := range clients {
+		resp = append(resp, newOAuthClientResponse(client))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// DeleteOAuthClient handles DELETE /api/oauth/clients/{id}. Every user's
+// authorization of the app is revoked with it, so its tokens stop working.
+func (cfg *ApiConfig) DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid client id")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete client")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	client, err := qtx.RevokeOAuthClient(r.Context(), database.RevokeOAuthClientParams{ID: id, OwnerID: userId})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "client not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error revoking OAuth client: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to delete client")
+		return
+	}
+	if err := qtx.RevokeOAuthGrantsForClient(r.Context(), client.ID); err != nil {
+		log.Printf("Error revoking OAuth grants: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to delete client")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to delete client")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAuthorizedApps handles GET /api/authorized-apps, listing the apps the
+// caller has let act on their account and what each may do
+func (cfg *ApiConfig) GetAuthorizedApps(w http.ResponseWriter, r *http.Request) {
+	type appResponse struct {
+		ID        uuid.UUID `json:"id"`
+		ClientID  string    `json:"client_id"`
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	grants, err := cfg.DB.ListOAuthGrants(r.Context(), userId)
+	if err != nil {
+		log.Printf("Error listing OAuth grants: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting authorized apps")
+		return
+	}
+	resp := make([]appResponse, 0, len(grants))
+	for _, grant := range grants {
+		resp = append(resp, appResponse(grant))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// DeleteAuthorizedApp handles DELETE /api/authorized-apps/{id}, taking
+// back an app's access. Its access and refresh tokens stop working
+// immediately.
+func (cfg *ApiConfig) DeleteAuthorizedApp(w http.ResponseWriter, r *http.Request) {
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid authorization id")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke app")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	revoked, err := qtx.RevokeOAuthGrant(r.Context(), database.RevokeOAuthGrantParams{ID: id, UserID: userId})
+	if err != nil {
+		log.Printf("Error revoking OAuth grant: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke app")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "authorized app not found")
+		return
+	}
+	if err := qtx.RevokeOAuthRefreshTokensForGrant(r.Context(), id); err != nil {
+		log.Printf("Error revoking OAuth refresh tokens: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke app")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke app")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
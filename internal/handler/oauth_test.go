@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRedirectURI(t *testing.T) {
+	for _, uri := range []string{
+		"https://app.example.com/callback",
+		"http://localhost:8000/callback",
+		"http://127.0.0.1:53682/",
+		"http://[::1]/cb",
+		"com.example.app:/oauth/callback",
+	} {
+		assert.NoError(t, validateRedirectURI(uri), uri)
+	}
+	for _, uri := range []string{
+		"",
+		"/callback",
+		"http://app.example.com/callback",
+		"https://app.example.com/callback#frag",
+		"javascript:alert(1)",
+		"https:///callback",
+	} {
+		assert.Error(t, validateRedirectURI(uri), uri)
+	}
+}
+
+func TestAuthorizeRedirect(t *testing.T) {
+	req := authorizeRequest{RedirectURI: "https://app.example.com/cb?keep=1", State: "xyz"}
+	w := httptest.NewRecorder()
+	req.redirect(w, httptest.NewRequest(http.MethodPost, "/oauth/authorize", nil), url.Values{"code": {"abc"}})
+	require.Equal(t, http.StatusFound, w.Code)
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "app.example.com", location.Host)
+	assert.Equal(t, url.Values{"keep": {"1"}, "code": {"abc"}, "state": {"xyz"}}, location.Query())
+}
+
+func TestConsentPageEscapes(t *testing.T) {
+	req := authorizeRequest{
+		Client:      database.OauthClient{Name: "<script>evil</script>", ClientID: "client"},
+		RedirectURI: "https://app.example.com/cb",
+		Scopes:      []string{auth.ScopeChirpsRead},
+		State:       `"><img src=x>`,
+	}
+	w := httptest.NewRecorder()
+	renderConsent(w, http.StatusOK, req.consentPage("", ""))
+	body := w.Body.String()
+	assert.NotContains(t, body, "<script>evil")
+	assert.NotContains(t, body, `"><img`)
+	assert.Contains(t, body, scopeDescriptions[auth.ScopeChirpsRead])
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+}
+
+func TestScopeDescriptionsCoverScopes(t *testing.T) {
+	for _, scope := range auth.Scopes {
+		assert.NotEmpty(t, scopeDescriptions[scope], scope)
+	}
+}
+
+func TestOAuthAccessTokensRefusedForSessions(t *testing.T) {
+	cfg := &ApiConfig{Secret: "secret"}
+	token, err := cfg.signAccessToken(auth.AccessClaims{
+		UserID:    uuid.New(),
+		SessionID: uuid.New(),
+		ClientID:  "client",
+		Scopes:    auth.Scopes,
+	}, time.Minute)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/sessions", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	_, _, err = cfg.authenticatedSession(r)
+	assert.ErrorIs(t, err, errOAuthAccessToken)
+
+	// even with profile:write an app can't change the email or password
+	r = httptest.NewRequest(http.MethodPut, "/api/users", strings.NewReader(`{"email":"a@example.com","password":"new"}`))
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	cfg.UpdateUserHandler(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuthenticateOAuthClientNeedsID(t *testing.T) {
+	cfg := &ApiConfig{Secret: "secret"}
+	r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader("grant_type=refresh_token"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	require.NoError(t, r.ParseForm())
+	_, err := cfg.authenticateOAuthClient(r)
+	assert.ErrorIs(t, err, errInvalidClient)
+}
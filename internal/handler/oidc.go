@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/oidc"
+)
+
+const (
+	// oidcLoginTTL is how long a user has to finish logging in at the provider
+	oidcLoginTTL = 10 * time.Minute
+	// oidcAccessTokenExpiry is the access token lifetime, in seconds, the
+	// same as LoginUser's default
+	oidcAccessTokenExpiry = 3600
+)
+
+var (
+	errNoVerifiedEmail   = errors.New("the identity provider did not confirm an email address")
+	errUnverifiedAccount = errors.New("an account with this email exists but hasn't verified it; log in with your password and verify your email first")
+)
+
+// GetOIDCProviders handles GET /api/login/oidc, listing the providers
+// users can log in with
+func (cfg *ApiConfig) GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	type providerResponse struct {
+		Name string `json:"name"`
+	}
+	names := make([]string, 0, len(cfg.OIDCProviders))
+	for name := range cfg.OIDCProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	resp := make([]providerResponse, 0, len(names))
+	for _, name := range names {
+		resp = append(resp, providerResponse{Name: name})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// StartOIDCLogin handles POST /api/login/oidc/{provider}. It returns the
+// provider URL to send the user to; the provider sends them back to the
+// configured redirect URL with a code and state for FinishOIDCLogin.
+func (cfg *ApiConfig) StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	type respBody struct {
+		AuthorizationURL string    `json:"authorization_url"`
+		ExpiresAt        time.Time `json:"expires_at"`
+	}
+	provider, ok := cfg.OIDCProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "unknown identity provider")
+		return
+	}
+	// state, nonce and verifier are each 256 random bits; the hex verifier
+	// is a valid PKCE code verifier
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to start login")
+			return
+		}
+		secrets[i] = secret
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("Error starting OIDC login: %v", err)
+		respondWithError(w, http.StatusBadGateway, "identity provider is unavailable")
+		return
+	}
+	expiresAt := time.Now().Add(oidcLoginTTL)
+	err = cfg.DB.CreateOIDCLoginState(r.Context(), database.CreateOIDCLoginStateParams{
+		StateHash:    cfg.hashToken(state),
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		log.Printf("Error saving OIDC login state: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to start login")
+		return
+	}
+	if err := cfg.DB.DeleteExpiredOIDCLoginStates(r.Context()); err != nil {
+		log.Printf("Error deleting expired OIDC login states: %v", err)
+	}
+	respondWithJSON(w, http.StatusOK, respBody{AuthorizationURL: authURL, ExpiresAt: expiresAt})
+}
+
+// OIDCCallbackPage handles GET /app/login/oidc/callback, the redirect URL
+// providers send the user back to, with a button that posts the code and
+// state to FinishOIDCLogin
+func (cfg *ApiConfig) OIDCCallbackPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if reason := q.Get("error"); reason != "" {
+		if description := q.Get("error_description"); description != "" {
+			reason = description
+		}
+		renderTokenPage(w, http.StatusBadRequest, tokenPage{
+			Title:    "Log in to Chirpy",
+			Messages: []string{"The identity provider didn't log you in: " + reason},
+		})
+		return
+	}
+	renderTokenPage(w, http.StatusOK, tokenPage{
+		Title:    "Log in to Chirpy",
+		Messages: []string{"Press the button to finish logging in."},
+		Action:   "/api/login/oidc/callback",
+		Fields:   map[string]string{"code": q.Get("code"), "state": q.Get("state")},
+		Button:   "Continue",
+	})
+}
+
+// FinishOIDCLogin handles POST /api/login/oidc/callback with the code and
+// state the provider sent the user back with. It responds like LoginUser,
+// including asking for a second factor when the account has one. The code
+// and state come as JSON from clients or as a form from OIDCCallbackPage.
+func (cfg *ApiConfig) FinishOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		State string `json:"state"`
+		Code  string `json:"code"`
+	}
+	params := parameters{}
+	if isFormPost(r) {
+		if err := r.ParseForm(); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		params.State, params.Code = r.PostForm.Get("state"), r.PostForm.Get("code")
+	} else if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// the state is deleted as it is read, so a callback can't be replayed
+	state, err := cfg.DB.UseOIDCLoginState(r.Context(), cfg.hashToken(params.State))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && time.Now().After(state.ExpiresAt)) {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired login state")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to log in")
+		return
+	}
+	provider, ok := cfg.OIDCProviders[state.Provider]
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "unknown identity provider")
+		return
+	}
+	claims, err := provider.Exchange(r.Context(), params.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("Error completing OIDC login: %v", err)
+		respondWithError(w, http.StatusUnauthorized, "identity provider login failed")
+		return
+	}
+
+	user, err := cfg.userForIdentity(r.Context(), claims)
+	if errors.Is(err, errNoVerifiedEmail) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, errUnverifiedAccount) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error linking OIDC identity: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to log in")
+		return
+	}
+
+	mfaRequired, err := cfg.mfaEnabled(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error checking two-factor status: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
+		return
+	}
+	if mfaRequired {
+		cfg.startMFAChallenge(w, r, user.ID, oidcAccessTokenExpiry)
+		return
+	}
+	cfg.completeLogin(w, r, user, oidcAccessTokenExpiry)
+}
+
+// userForIdentity returns the user an external identity logs in as. An
+// identity seen before keeps its user. A new one is linked to the account
+// with the same email, provided both the provider and Chirpy have verified
+// it, or else gets a new account.
+func (cfg *ApiConfig) userForIdentity(ctx context.Context, claims oidc.Claims) (database.User, error) {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	identity, err := qtx.GetExternalIdentity(ctx, database.GetExternalIdentityParams{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		if err := qtx.TouchExternalIdentity(ctx, database.TouchExternalIdentityParams{ID: identity.ID, Email: claims.Email}); err != nil {
+			return database.User{}, err
+		}
+		user, err := qtx.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			return database.User{}, err
+		}
+		return user, tx.Commit()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if !claims.EmailVerified {
+		return database.User{}, errNoVerifiedEmail
+	}
+	email, err := normalizeEmail(claims.Email)
+	if err != nil {
+		return database.User{}, errNoVerifiedEmail
+	}
+	user, err := qtx.GetUserByEmail(ctx, email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = cfg.createExternalUser(ctx, qtx, email)
+		if err != nil {
+			return database.User{}, err
+		}
+	case err != nil:
+		return database.User{}, err
+	case !user.EmailVerifiedAt.Valid:
+		// whoever signed up with this address never proved they own it;
+		// linking would hand their account, password and all, to the
+		// provider's user
+		return database.User{}, errUnverifiedAccount
+	}
+
+	_, err = qtx.CreateExternalIdentity(ctx, database.CreateExternalIdentityParams{
+		UserID:  user.ID,
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		Email:   email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, tx.Commit()
+}
+
+// createExternalUser creates an account for a new provider user. Its
+// password is random, so it can only be logged into through the provider
+// until the user sets one with a password reset.
+func (cfg *ApiConfig) createExternalUser(ctx context.Context, q *database.Queries, email string) (database.User, error) {
+	password, err := auth.MakeRefreshToken()
+	if err != nil {
+		return database.User{}, err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return database.User{}, err
+	}
+	user, err := q.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: hashedPassword})
+	if err != nil {
+		return database.User{}, err
+	}
+	// the provider has verified the address
+	return q.SetVerifiedEmail(ctx, database.SetVerifiedEmailParams{ID: user.ID, Email: email})
+}
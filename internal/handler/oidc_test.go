@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/oidc"
+	"github.com/Glenn444/chirpy/internal/oidc/oidctest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOIDCProviders(t *testing.T) {
+	cfg := &ApiConfig{OIDCProviders: map[string]*oidc.Provider{
+		"okta":   oidc.NewProvider(oidc.Config{Name: "okta"}, nil),
+		"google": oidc.NewProvider(oidc.Config{Name: "google"}, nil),
+	}}
+	w := httptest.NewRecorder()
+	cfg.GetOIDCProviders(w, httptest.NewRequest(http.MethodGet, "/api/login/oidc", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var got []map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, []map[string]string{{"name": "google"}, {"name": "okta"}}, got)
+}
+
+func TestStartOIDCLoginUnknownProvider(t *testing.T) {
+	cfg := &ApiConfig{}
+	r := httptest.NewRequest(http.MethodPost, "/api/login/oidc/nope", nil)
+	r.SetPathValue("provider", "nope")
+	w := httptest.NewRecorder()
+	cfg.StartOIDCLogin(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestOIDCLoginThroughCallbackPage(t *testing.T) {
+	idp := oidctest.New("chirpy", "s3cret")
+	defer idp.Close()
+	idp.SetUser(oidctest.User{Subject: "user-1", Email: "walt@example.com", EmailVerified: true})
+	cfg, db := newFakeConfig(t)
+	cfg.OIDCProviders = map[string]*oidc.Provider{"mock": oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		Issuer:       idp.Issuer(),
+		ClientID:     "chirpy",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://chirpy.example/app/login/oidc/callback",
+		Scopes:       []string{"email"},
+	}, nil)}
+
+	// state_hash, provider, nonce, code_verifier, expires_at
+	var saved []driver.Value
+	db.onFunc("CreateOIDCLoginState", func(args []driver.Value) ([][]driver.Value, error) {
+		saved = args
+		return nil, nil
+	})
+	db.onFunc("UseOIDCLoginState", func(args []driver.Value) ([][]driver.Value, error) {
+		if saved == nil || args[0] != saved[0] {
+			return nil, nil
+		}
+		return [][]driver.Value{{uuid.New().String(), saved[0], saved[1], saved[2], saved[3], saved[4], fakeNow}}, nil
+	})
+	user := database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Email: "walt@example.com", Role: "user"}
+	db.on("CreateUser", user)
+	user.EmailVerifiedAt = sql.NullTime{Time: fakeNow, Valid: true}
+	db.on("SetVerifiedEmail", user)
+	db.on("CreateExternalIdentity", database.ExternalIdentity{ID: uuid.New(), UserID: user.ID, Issuer: idp.Issuer(), Subject: "user-1", Email: user.Email, CreatedAt: fakeNow, LastLoginAt: fakeNow})
+	db.on("CreateSession", database.Session{ID: uuid.New(), UserID: user.ID, CreatedAt: fakeNow, LastUsedAt: fakeNow, ExpiresAt: fakeNow.Add(time.Hour)})
+	db.on("CreateRefreshToken", database.RefreshToken{UserID: user.ID, ExpiresAt: fakeNow.Add(time.Hour), FamilyID: uuid.New()})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/login/oidc/mock", nil)
+	r.SetPathValue("provider", "mock")
+	w := httptest.NewRecorder()
+	cfg.StartOIDCLogin(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var started struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&started))
+
+	// the provider sends the browser back to the redirect URL with a GET
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(started.AuthorizationURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/app/login/oidc/callback", callback.Path)
+
+	w = httptest.NewRecorder()
+	cfg.OIDCCallbackPage(w, httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	code, state := callback.Query().Get("code"), callback.Query().Get("state")
+	assert.Contains(t, w.Body.String(), `action="/api/login/oidc/callback"`)
+	assert.Contains(t, w.Body.String(), `name="code" value="`+code+`"`)
+	assert.Contains(t, w.Body.String(), `name="state" value="`+state+`"`)
+	assert.Empty(t, db.called("UseOIDCLoginState"))
+
+	r = httptest.NewRequest(http.MethodPost, "/api/login/oidc/callback", strings.NewReader(url.Values{"code": {code}, "state": {state}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	cfg.FinishOIDCLogin(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"token"`)
+	calls := db.called("CreateExternalIdentity")
+	require.Len(t, calls, 1)
+	// user_id, issuer, subject, email
+	assert.Equal(t, []driver.Value{user.ID.String(), idp.Issuer(), "user-1", "walt@example.com"}, calls[0].Args)
+	assert.Len(t, db.called("CreateSession"), 1)
+}
+
+func TestOIDCCallbackPageShowsProviderError(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	w := httptest.NewRecorder()
+	cfg.OIDCCallbackPage(w, httptest.NewRequest(http.MethodGet, "/app/login/oidc/callback?error=access_denied&error_description=User+cancelled&state=s", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "User cancelled")
+	assert.NotContains(t, w.Body.String(), "<form")
+	assert.Empty(t, db.calls)
+}
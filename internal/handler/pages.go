@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// tokenPage is a page opened from a link carrying a token, such as one in
+// an email. Mail scanners and link previews fetch links without anyone
+// pressing anything, so these pages never use the token themselves: they
+// show a form that posts it when the user presses Button. A page without
+// an Action only shows its messages, such as the result of that post.
+type tokenPage struct {
+	Title    string
+	Messages []string
+	Action   string
+	// Fields are posted as hidden inputs
+	Fields map[string]string
+	// NewPassword asks for a new password alongside the fields
+	NewPassword bool
+	Button      string
+}
+
+var tokenPageTemplate = template.Must(template.New("token-page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} - Chirpy</title>
+<style>
+body { font-family: sans-serif; max-width: 28rem; margin: 3rem auto; padding: 0 1rem; }
+label { display: block; margin: 1rem 0; }
+input[type=password] { display: block; width: 100%; box-sizing: border-box; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Messages}}<p>{{.}}</p>
+{{end}}{{if .Action}}<form method="post" action="{{.Action}}">
+{{range $name, $value := .Fields}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}{{if .NewPassword}}<label>New password <input type="password" name="password" autocomplete="new-password" required></label>
+{{end}}<button type="submit">{{.Button}}</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// renderTokenPage writes page. The token is in the URL, so it mustn't leak
+// to other sites through the referrer, and the page can't be framed.
+func renderTokenPage(w http.ResponseWriter, status int, page tokenPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+	w.WriteHeader(status)
+	if err := tokenPageTemplate.Execute(w, page); err != nil {
+		log.Printf("Error rendering %s page: %v", page.Title, err)
+	}
+}
+
+// isFormPost reports whether r was posted by one of the token pages rather
+// than sent as JSON by a client
+func isFormPost(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// pageCursor is the keyset position of the last item on a page.
+// Clients only ever see it as an opaque string.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+type pageParams struct {
+	Limit  int32
+	Cursor *pageCursor
+	Desc   bool
+}
+
+// parsePageParams reads limit, cursor and sort from the query string
+func parsePageParams(r *http.Request) (pageParams, error) {
+	query := r.URL.Query()
+	limit, err := parsePageLimit(r)
+	if err != nil {
+		return pageParams{}, err
+	}
+	params := pageParams{Limit: limit}
+
+	switch strings.ToLower(query.Get("sort")) {
+	case "", "asc":
+		params.Desc = false
+	case "desc":
+		params.Desc = true
+	default:
+		return pageParams{}, errors.New("sort must be asc or desc")
+	}
+
+	if rawCursor := query.Get("cursor"); rawCursor != "" {
+		cursor, err := decodeCursor(rawCursor)
+		if err != nil {
+			return pageParams{}, err
+		}
+		params.Cursor = &cursor
+	}
+
+	return params, nil
+}
+
+// parsePageLimit reads only the limit query parameter
+func parsePageLimit(r *http.Request) (int32, error) {
+	rawLimit := r.URL.Query().Get("limit")
+	if rawLimit == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return int32(limit), nil
+}
+
+// nullCursor splits the cursor into the nullable arguments the keyset queries take
+func (p pageParams) nullCursor() (sql.NullTime, uuid.NullUUID) {
+	if p.Cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: p.Cursor.CreatedAt, Valid: true},
+		uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := fmt.Sprintf("%s|%s", createdAt.UTC().Format(time.RFC3339Nano), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	createdAtStr, idStr, found := strings.Cut(string(raw), "|")
+	if !found {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	return pageCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// trimChirpPage drops the extra row a keyset query was asked for and
+// returns the cursor of the next page, or "" on the last page
+func trimChirpPage(chirps []database.Chirp, limit int32) ([]database.Chirp, string) {
+	if len(chirps) <= int(limit) {
+		return chirps, ""
+	}
+	chirps = chirps[:limit]
+	last := chirps[len(chirps)-1]
+	return chirps, encodeCursor(last.CreatedAt, last.ID)
+}
+
+// setNextPage advertises the next page through the Link and X-Next-Cursor
+// headers. X-Next-Cursor stands in for a next_cursor field on purpose:
+// these endpoints returned a bare JSON array before they were paged, and
+// wrapping it in an object to make room for the cursor would break every
+// existing client.
+func setNextPage(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+	query := r.URL.Query()
+	query.Set("cursor", nextCursor)
+	next := *r.URL
+	next.RawQuery = query.Encode()
+
+	w.Header().Set("X-Next-Cursor", nextCursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 6, 2, 9, 30, 0, 123456000, time.UTC)
+	id := uuid.New()
+
+	cursor, err := decodeCursor(encodeCursor(createdAt, id))
+	assert.NoError(t, err)
+	assert.True(t, createdAt.Equal(cursor.CreatedAt))
+	assert.Equal(t, id, cursor.ID)
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	_, err := decodeCursor("not-a-cursor")
+	assert.Error(t, err)
+}
+
+func TestParsePageParams(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/chirps", nil)
+	page, err := parsePageParams(req)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(defaultPageLimit), page.Limit)
+	assert.False(t, page.Desc)
+	assert.Nil(t, page.Cursor)
+
+	req = httptest.NewRequest("GET", "/api/chirps?limit=1000&sort=desc", nil)
+	page, err = parsePageParams(req)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(maxPageLimit), page.Limit)
+	assert.True(t, page.Desc)
+
+	req = httptest.NewRequest("GET", "/api/chirps?sort=sideways", nil)
+	_, err = parsePageParams(req)
+	assert.Error(t, err)
+
+	req = httptest.NewRequest("GET", "/api/chirps?limit=0", nil)
+	_, err = parsePageParams(req)
+	assert.Error(t, err)
+}
+
+func TestSetNextPage(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/chirps?limit=10&sort=desc", nil)
+	rec := httptest.NewRecorder()
+
+	setNextPage(rec, req, "abc")
+	assert.Equal(t, "abc", rec.Header().Get("X-Next-Cursor"))
+	assert.Equal(t, `</api/chirps?cursor=abc&limit=10&sort=desc>; rel="next"`, rec.Header().Get("Link"))
+}
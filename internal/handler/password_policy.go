@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/password"
+)
+
+// passwordPolicy returns the configured PasswordPolicy, falling back to
+// password.DefaultPolicy()
+func (cfg *ApiConfig) passwordPolicy() *password.Policy {
+	cfg.passwordPolicyOnce.Do(func() {
+		if cfg.PasswordPolicy == nil {
+			cfg.PasswordPolicy = password.DefaultPolicy()
+		}
+	})
+	return cfg.PasswordPolicy
+}
+
+// checkNewPassword responds with 400 and every violated rule, and returns
+// false, when pw isn't acceptable for the account with email
+func (cfg *ApiConfig) checkNewPassword(w http.ResponseWriter, pw, email string) bool {
+	violations := cfg.passwordViolations(pw, email)
+	if len(violations) > 0 {
+		respondWithViolations(w, violations)
+		return false
+	}
+	return true
+}
+
+// passwordViolations lists the rules pw breaks for the account with email.
+// If the breach corpus can't be read the password is judged without it.
+func (cfg *ApiConfig) passwordViolations(pw, email string) []password.Violation {
+	violations, err := cfg.passwordPolicy().Check(pw, email)
+	if err != nil {
+		log.Printf("Error checking breached passwords: %v", err)
+	}
+	return violations
+}
+
+func respondWithViolations(w http.ResponseWriter, violations []password.Violation) {
+	type respBody struct {
+		Error      string               `json:"error"`
+		Violations []password.Violation `json:"violations"`
+	}
+	respondWithJSON(w, http.StatusBadRequest, respBody{
+		Error:      "password does not meet the requirements",
+		Violations: violations,
+	})
+}
+
+// rehashPassword stores a fresh hash of pw for user when theirs was made
+// with a lower cost than auth.PasswordCost. Failing only means it is tried
+// again next login.
+func (cfg *ApiConfig) rehashPassword(r *http.Request, user database.User, pw string) {
+	if !auth.NeedsRehash(user.HashedPassword) {
+		return
+	}
+	hashed, err := auth.HashPassword(pw)
+	if err != nil {
+		log.Printf("Error rehashing password: %v", err)
+		return
+	}
+	err = cfg.DB.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{ID: user.ID, HashedPassword: hashed})
+	if err != nil {
+		log.Printf("Error rehashing password: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckNewPassword(t *testing.T) {
+	cfg := &ApiConfig{}
+
+	w := httptest.NewRecorder()
+	assert.True(t, cfg.checkNewPassword(w, "correct horse battery staple", "walt@breakingbad.com"))
+
+	w = httptest.NewRecorder()
+	assert.False(t, cfg.checkNewPassword(w, "walt", "walt@breakingbad.com"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var body struct {
+		Error      string `json:"error"`
+		Violations []struct {
+			Code string `json:"code"`
+		} `json:"violations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Violations, 2)
+	assert.Equal(t, "too_short", body.Violations[0].Code)
+	assert.Equal(t, "contains_email", body.Violations[1].Code)
+}
+
+func TestDefaultPasswordPolicyIsShared(t *testing.T) {
+	cfg := &ApiConfig{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, cfg.checkNewPassword(httptest.NewRecorder(), "correct horse battery staple", "walt@breakingbad.com"))
+		}()
+	}
+	wg.Wait()
+	assert.Same(t, cfg.PasswordPolicy, cfg.passwordPolicy())
+}
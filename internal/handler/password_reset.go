@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/mailer"
+	"github.com/Glenn444/chirpy/internal/password"
+	"github.com/google/uuid"
+)
+
+const (
+	// passwordResetTTL is how long a reset link works
+	passwordResetTTL = time.Hour
+	// mailTimeout bounds sending one email
+	mailTimeout = 30 * time.Second
+)
+
+// sendMail delivers msg in the background so a slow mail server, or the
+// lack of one, never shows in response times
+func (cfg *ApiConfig) sendMail(msg mailer.Message) {
+	if cfg.Mailer == nil {
+		log.Printf("No mailer configured, dropping mail to %s: %s", msg.To, msg.Subject)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := cfg.Mailer.Send(ctx, msg); err != nil {
+			log.Printf("Error sending mail to %s: %v", msg.To, err)
+		}
+	}()
+}
+
+// publicLink builds an absolute link to path on the public site
+func (cfg *ApiConfig) publicLink(path string, query url.Values) string {
+	return cfg.PublicURL + path + "?" + query.Encode()
+}
+
+// ForgotPassword handles POST /api/password/forgot by mailing a reset link.
+// It answers the same whether or not the email has an account, so it
+// can't be used to find out who is registered.
+func (cfg *ApiConfig) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, err := cfg.userByEmail(r.Context(), params.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start password reset")
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to start password reset")
+		return
+	}
+	err = cfg.DB.CreatePasswordReset(r.Context(), database.CreatePasswordResetParams{
+		TokenHash: cfg.hashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		log.Printf("Error saving password reset: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to start password reset")
+		return
+	}
+
+	link := cfg.publicLink("/app/reset-password", url.Values{"token": {token}})
+	cfg.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"Open this link within the next hour to choose a new one:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email; your password hasn't changed.\n", link),
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPasswordPage handles GET /app/reset-password, where emailed reset
+// links point, with a form that posts a new password and the token to
+// ResetPassword
+func (cfg *ApiConfig) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	renderTokenPage(w, http.StatusOK, resetPasswordForm(r.URL.Query().Get("token")))
+}
+
+func resetPasswordForm(token string, messages ...string) tokenPage {
+	return tokenPage{
+		Title:       "Reset your password",
+		Messages:    append([]string{"Choose a new password for your Chirpy account."}, messages...),
+		Action:      "/api/password/reset",
+		Fields:      map[string]string{"token": token},
+		NewPassword: true,
+		Button:      "Set password",
+	}
+}
+
+var errResetTokenInvalid = errors.New("invalid or expired reset token")
+
+// ResetPassword handles POST /api/password/reset, setting a new password
+// with a token from ForgotPassword. Every token the user has outstanding
+// stops working, and every session is logged out. The token and password
+// come as JSON from clients or as a form from ResetPasswordPage, which
+// gets a page back.
+func (cfg *ApiConfig) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	params := parameters{}
+	form := isFormPost(r)
+	if form {
+		if err := r.ParseForm(); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		params.Token, params.Password = r.PostForm.Get("token"), r.PostForm.Get("password")
+	} else if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	violations, err := cfg.resetPassword(r.Context(), params.Token, params.Password)
+	if err != nil && !errors.Is(err, errResetTokenInvalid) {
+		log.Printf("Error resetting password: %v", err)
+	}
+	if form {
+		switch {
+		case errors.Is(err, errResetTokenInvalid):
+			renderTokenPage(w, http.StatusBadRequest, tokenPage{
+				Title:    "Reset your password",
+				Messages: []string{"This reset link is invalid or has expired. Ask for a new one from the login page."},
+			})
+		case err != nil:
+			renderTokenPage(w, http.StatusInternalServerError, tokenPage{
+				Title:    "Reset your password",
+				Messages: []string{"Something went wrong resetting your password. Please try again."},
+			})
+		case len(violations) > 0:
+			var messages []string
+			for _, v := range violations {
+				messages = append(messages, v.Message)
+			}
+			renderTokenPage(w, http.StatusBadRequest, resetPasswordForm(params.Token, messages...))
+		default:
+			renderTokenPage(w, http.StatusOK, tokenPage{
+				Title:    "Password changed",
+				Messages: []string{"Your password has been changed and you have been logged out everywhere. You can log in with the new password now."},
+			})
+		}
+		return
+	}
+
+	switch {
+	case errors.Is(err, errResetTokenInvalid):
+		respondWithError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "failed to reset password")
+	case len(violations) > 0:
+		respondWithViolations(w, violations)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// resetPassword sets newPassword for the account token was issued to. A
+// password the policy refuses changes nothing and leaves the token usable.
+func (cfg *ApiConfig) resetPassword(ctx context.Context, token, newPassword string) ([]password.Violation, error) {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	reset, err := qtx.UsePasswordReset(ctx, cfg.hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errResetTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := qtx.GetUserByID(ctx, reset.UserID)
+	if err != nil {
+		return nil, err
+	}
+	// refusing the password rolls back, leaving the token usable
+	if violations := cfg.passwordViolations(newPassword, user.Email); len(violations) > 0 {
+		return violations, nil
+	}
+
+	hashedPassword, err := auth.HashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+	err = qtx.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:             reset.UserID,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("updating password: %w", err)
+	}
+	if err := qtx.ExpirePasswordResets(ctx, reset.UserID); err != nil {
+		return nil, err
+	}
+	if err := revokeOtherSessions(ctx, qtx, reset.UserID, uuid.Nil); err != nil {
+		return nil, fmt.Errorf("revoking sessions: %w", err)
+	}
+	return nil, tx.Commit()
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/Glenn444/chirpy/internal/mailer"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordResetLink(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	mail := fakeMailer(make(chan mailer.Message, 1))
+	cfg.Mailer, cfg.PublicURL = mail, "https://chirpy.example"
+	user := database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Email: "walt@breakingbad.com", Role: "user"}
+	db.on("GetUserByEmail", user)
+	db.on("GetUserByID", user)
+
+	w := httptest.NewRecorder()
+	cfg.ForgotPassword(w, httptest.NewRequest(http.MethodPost, "/api/password/forgot", strings.NewReader(`{"email":"walt@breakingbad.com"}`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	link := mailedLink(t, mail, cfg)
+	assert.Equal(t, "/app/reset-password", link.Path)
+	token := link.Query().Get("token")
+	require.NotEmpty(t, token)
+
+	// opening the link only shows the form
+	w = httptest.NewRecorder()
+	cfg.ResetPasswordPage(w, httptest.NewRequest(http.MethodGet, link.RequestURI(), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `action="/api/password/reset"`)
+	assert.Contains(t, w.Body.String(), `value="`+token+`"`)
+	assert.Empty(t, db.called("UsePasswordReset"))
+
+	post := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}, "password": {password}}
+		r := httptest.NewRequest(http.MethodPost, "/api/password/reset", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		cfg.ResetPassword(w, r)
+		return w
+	}
+	reset := database.PasswordReset{ID: uuid.New(), TokenHash: cfg.hashToken(token), UserID: user.ID, ExpiresAt: fakeNow.Add(time.Hour), CreatedAt: fakeNow}
+	db.on("UsePasswordReset", reset)
+
+	// a weak password shows the form again with what is wrong
+	w = post("walt")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `type="password"`)
+	assert.Empty(t, db.called("UpdateUserPassword"))
+
+	w = post("correct horse battery staple")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Password changed")
+	assert.Len(t, db.called("UpdateUserPassword"), 1)
+
+	// the token is used up
+	db.on("UsePasswordReset")
+	w = post("correct horse battery staple")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Len(t, db.called("UpdateUserPassword"), 1)
+}
+
+func TestResetPasswordJSON(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	w := httptest.NewRecorder()
+	cfg.ResetPassword(w, httptest.NewRequest(http.MethodPost, "/api/password/reset", strings.NewReader(`{"token":"nope","password":"correct horse battery staple"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Empty(t, db.called("UpdateUserPassword"))
+}
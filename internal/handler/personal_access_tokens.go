@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxTokenNameLength = 100
+	// maxTokenLifetimeDays bounds expires_in_days; leaving it out makes a
+	// token that never expires
+	maxTokenLifetimeDays = 366
+)
+
+var (
+	errInvalidPersonalAccessToken = errors.New("invalid personal access token")
+	errInsufficientScope          = errors.New("token is missing the required scope")
+)
+
+// validatePersonalAccessToken returns the owner of token if it is active
+// and was granted scope, and records that it was used
+func (cfg *ApiConfig) validatePersonalAccessToken(ctx context.Context, token, scope string) (uuid.UUID, error) {
+	pat, err := cfg.DB.GetActivePersonalAccessToken(ctx, cfg.hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, errInvalidPersonalAccessToken
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !auth.HasScope(pat.Scopes, scope) {
+		return uuid.Nil, fmt.Errorf("%w %s", errInsufficientScope, scope)
+	}
+	if err := cfg.DB.TouchPersonalAccessToken(ctx, pat.ID); err != nil {
+		log.Printf("Error recording personal access token use: %v", err)
+	}
+	return pat.UserID, nil
+}
+
+type personalAccessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	// Token is only ever returned when the token is created
+	Token string `json:"token,omitempty"`
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func newPersonalAccessTokenResponse(pat database.PersonalAccessToken) personalAccessTokenResponse {
+	return personalAccessTokenResponse{
+		ID:         pat.ID,
+		Name:       pat.Name,
+		Scopes:     pat.Scopes,
+		CreatedAt:  pat.CreatedAt,
+		LastUsedAt: nullTimePtr(pat.LastUsedAt),
+		ExpiresAt:  nullTimePtr(pat.ExpiresAt),
+	}
+}
+
+// CreatePersonalAccessToken handles POST /api/tokens. Managing tokens needs
+// a login session; a personal access token can't mint more of itself.
+func (cfg *ApiConfig) CreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	if params.Name == "" || len(params.Name) > maxTokenNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("name must be 1 to %d characters", maxTokenNameLength))
+		return
+	}
+	scopes, err := auth.ValidateScopes(params.Scopes)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if params.ExpiresInDays < 0 || params.ExpiresInDays > maxTokenLifetimeDays {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("expires_in_days must be at most %d", maxTokenLifetimeDays))
+		return
+	}
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, params.ExpiresInDays), Valid: true}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	pat, err := cfg.DB.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userId,
+		Name:      params.Name,
+		TokenHash: cfg.hashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("Error creating personal access token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	resp := newPersonalAccessTokenResponse(pat)
+	resp.Token = token
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// GetPersonalAccessTokens handles GET /api/tokens, listing the caller's
+// active tokens, newest first
+func (cfg *ApiConfig) GetPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	pats, err := cfg.DB.ListPersonalAccessTokens(r.Context(), userId)
+	if err != nil {
+		log.Printf("Error listing personal access tokens: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting tokens")
+		return
+	}
+	resp := make([]personalAccessTokenResponse, 0, len(pats))
+	for _, pat := range pats {
+		resp = append(resp, newPersonalAccessTokenResponse(pat))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// DeletePersonalAccessToken handles DELETE /api/tokens/{id}; the token
+// stops working immediately
+func (cfg *ApiConfig) DeletePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	tokenId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid token id")
+		return
+	}
+	revoked, err := cfg.DB.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenId,
+		UserID: userId,
+	})
+	if err != nil {
+		log.Printf("Error revoking personal access token: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke token")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "token not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
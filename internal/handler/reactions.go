@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// validReactions is the fixed set of reactions a chirp accepts; "like"
+// backs the /likes endpoints
+var validReactions = map[string]bool{
+	"like":  true,
+	"love":  true,
+	"laugh": true,
+	"wow":   true,
+	"sad":   true,
+	"angry": true,
+}
+
+// LikeChirp handles POST /api/chirps/{chirpID}/likes
+func (cfg *ApiConfig) LikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.setReaction(w, r, "like", true)
+}
+
+// UnlikeChirp handles DELETE /api/chirps/{chirpID}/likes
+func (cfg *ApiConfig) UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.setReaction(w, r, "like", false)
+}
+
+// AddReaction handles POST /api/chirps/{chirpID}/reactions/{reaction}
+func (cfg *ApiConfig) AddReaction(w http.ResponseWriter, r *http.Request) {
+	cfg.setReaction(w, r, r.PathValue("reaction"), true)
+}
+
+// RemoveReaction handles DELETE /api/chirps/{chirpID}/reactions/{reaction}
+func (cfg *ApiConfig) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	cfg.setReaction(w, r, r.PathValue("reaction"), false)
+}
+
+// setReaction adds or removes the caller's reaction and responds with the
+// chirp's updated counts. Both directions are idempotent, and counts are
+// aggregated from chirp_reactions so concurrent reactions can't skew them.
+func (cfg *ApiConfig) setReaction(w http.ResponseWriter, r *http.Request, reaction string, add bool) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeReactionsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	if !validReactions[reaction] {
+		respondWithError(w, http.StatusBadRequest, "unknown reaction")
+		return
+	}
+	// taking a reaction back is always allowed
+	if add && !cfg.requireVerifiedEmail(w, r, userId, restrictReact) {
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+	chirp, err := cfg.DB.GetChirp(r.Context(), chirpId)
+	if err != nil || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+
+	if add {
+		err = cfg.DB.AddReaction(r.Context(), database.AddReactionParams{
+			UserID:   userId,
+			ChirpID:  chirpId,
+			Reaction: reaction,
+		})
+	} else {
+		err = cfg.DB.RemoveReaction(r.Context(), database.RemoveReactionParams{
+			UserID:   userId,
+			ChirpID:  chirpId,
+			Reaction: reaction,
+		})
+	}
+	if err != nil {
+		log.Printf("Error updating reaction: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to update reaction")
+		return
+	}
+
+	resp := []chirpResponse{newChirpResponse(chirp)}
+	viewer := uuid.NullUUID{UUID: userId, Valid: true}
+	if err := cfg.decorateChirps(r.Context(), viewer, resp); err != nil {
+		log.Printf("Error counting reactions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to count reactions")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp[0])
+}
+
+// attachReactions fills in reaction counts for chirps and, when viewer is
+// set, which of them the viewer has liked or reacted to
+func (cfg *ApiConfig) attachReactions(ctx context.Context, viewer uuid.NullUUID, chirps []chirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(chirps))
+	byID := make(map[uuid.UUID]*chirpResponse, len(chirps))
+	for i := range chirps {
+		ids = append(ids, chirps[i].ID)
+		byID[chirps[i].ID] = &chirps[i]
+	}
+
+	counts, err := cfg.DB.CountReactions(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, count := range counts {
+		chirp := byID[count.ChirpID]
+		if chirp.Reactions == nil {
+			chirp.Reactions = map[string]int64{}
+		}
+		chirp.Reactions[count.Reaction] = count.ReactionCount
+		if count.Reaction == "like" {
+			chirp.LikeCount = count.ReactionCount
+		}
+	}
+
+	if !viewer.Valid {
+		return nil
+	}
+	mine, err := cfg.DB.GetUserReactions(ctx, database.GetUserReactionsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+	for i := range chirps {
+		likedByMe := false
+		chirps[i].LikedByMe = &likedByMe
+	}
+	for _, reaction := range mine {
+		chirp := byID[reaction.ChirpID]
+		chirp.MyReactions = append(chirp.MyReactions, reaction.Reaction)
+		if reaction.Reaction == "like" {
+			*chirp.LikedByMe = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReactions keeps chirp_reactions for one chirp, keyed like its
+// primary key, so adding twice counts once as ON CONFLICT DO NOTHING does
+func fakeReactions(db *fakeDB, chirpId uuid.UUID) {
+	type key struct{ user, reaction string }
+	rows := map[key]bool{}
+	db.onFunc("AddReaction", func(args []driver.Value) ([][]driver.Value, error) {
+		rows[key{args[0].(string), args[2].(string)}] = true
+		return nil, nil
+	})
+	db.onFunc("RemoveReaction", func(args []driver.Value) ([][]driver.Value, error) {
+		delete(rows, key{args[0].(string), args[2].(string)})
+		return nil, nil
+	})
+	db.onFunc("CountReactions", func([]driver.Value) ([][]driver.Value, error) {
+		counts := map[string]int64{}
+		for k := range rows {
+			counts[k.reaction]++
+		}
+		var result [][]driver.Value
+		for reaction, count := range counts {
+			result = append(result, []driver.Value{chirpId.String(), reaction, count})
+		}
+		return result, nil
+	})
+	db.onFunc("GetUserReactions", func(args []driver.Value) ([][]driver.Value, error) {
+		var result [][]driver.Value
+		for k := range rows {
+			if k.user == args[0].(string) {
+				result = append(result, []driver.Value{chirpId.String(), k.reaction})
+			}
+		}
+		return result, nil
+	})
+}
+
+func TestReactionCounts(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, UserID: uuid.NullUUID{UUID: uuid.New(), Valid: true}, Body: "hello", Kind: chirpKindChirp}
+	db.on("GetChirp", chirp)
+	db.on("GetUserByID", database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Role: "user"})
+	fakeReactions(db, chirp.ID)
+
+	walt, jesse := uuid.New(), uuid.New()
+	react := func(userId uuid.UUID, method, reaction string) chirpResponse {
+		t.Helper()
+		r := httptest.NewRequest(method, "/api/chirps/"+chirp.ID.String()+"/reactions/"+reaction, nil)
+		r.SetPathValue("chirpID", chirp.ID.String())
+		r.SetPathValue("reaction", reaction)
+		w := httptest.NewRecorder()
+		if method == http.MethodPost {
+			cfg.AddReaction(w, authorize(t, cfg, r, userId))
+		} else {
+			cfg.RemoveReaction(w, authorize(t, cfg, r, userId))
+		}
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp chirpResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		sort.Strings(resp.MyReactions)
+		return resp
+	}
+
+	resp := react(walt, http.MethodPost, "like")
+	assert.Equal(t, int64(1), resp.LikeCount)
+	// liking again changes nothing
+	resp = react(walt, http.MethodPost, "like")
+	assert.Equal(t, int64(1), resp.LikeCount)
+	require.NotNil(t, resp.LikedByMe)
+	assert.True(t, *resp.LikedByMe)
+
+	react(jesse, http.MethodPost, "like")
+	resp = react(jesse, http.MethodPost, "love")
+	assert.Equal(t, int64(2), resp.LikeCount)
+	assert.Equal(t, map[string]int64{"like": 2, "love": 1}, resp.Reactions)
+	assert.Equal(t, []string{"like", "love"}, resp.MyReactions)
+
+	react(walt, http.MethodDelete, "like")
+	// and so does taking it back twice
+	resp = react(walt, http.MethodDelete, "like")
+	assert.Equal(t, int64(1), resp.LikeCount)
+	assert.Equal(t, map[string]int64{"like": 1, "love": 1}, resp.Reactions)
+	require.NotNil(t, resp.LikedByMe)
+	assert.False(t, *resp.LikedByMe)
+	assert.Empty(t, resp.MyReactions)
+}
+
+func TestUnknownReaction(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	db.on("GetUserByID", database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Role: "user"})
+	chirpId := uuid.New()
+	r := httptest.NewRequest(http.MethodPost, "/api/chirps/"+chirpId.String()+"/reactions/meh", nil)
+	r.SetPathValue("chirpID", chirpId.String())
+	r.SetPathValue("reaction", "meh")
+	w := httptest.NewRecorder()
+	cfg.AddReaction(w, authorize(t, cfg, r, uuid.New()))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, db.called("AddReaction"))
+}
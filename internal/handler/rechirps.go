@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	chirpKindChirp   = "chirp"
+	chirpKindRechirp = "rechirp"
+	chirpKindQuote   = "quote"
+)
+
+// resolveOriginal returns the chirp a rechirp or quote of id should point
+// at: rechirping a rechirp reposts the chirp it came from
+func (cfg *ApiConfig) resolveOriginal(ctx context.Context, q *database.Queries, id uuid.UUID) (database.Chirp, error) {
+	original, err := q.GetChirp(ctx, id)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if original.Kind == chirpKindRechirp {
+		if !original.OriginalID.Valid {
+			return database.Chirp{}, sql.ErrNoRows
+		}
+		original, err = q.GetChirp(ctx, original.OriginalID.UUID)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	if original.DeletedAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return original, nil
+}
+
+// Rechirp handles POST /api/chirps/{chirpID}/rechirp
+func (cfg *ApiConfig) Rechirp(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	if !cfg.requireVerifiedEmail(w, r, userId, restrictChirp) {
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+	original, err := cfg.resolveOriginal(r.Context(), cfg.DB, chirpId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+
+	rechirp, err := cfg.DB.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:       "",
+		UserID:     uuid.NullUUID{UUID: userId, Valid: true},
+		Kind:       chirpKindRechirp,
+		OriginalID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondWithError(w, http.StatusConflict, "you have already rechirped this chirp")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating rechirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to rechirp")
+		return
+	}
+
+	resp := []chirpResponse{newChirpResponse(rechirp)}
+	if err := cfg.decorateChirps(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, resp); err != nil {
+		log.Printf("Error loading rechirped chirp: %v", err)
+	}
+	respondWithJSON(w, http.StatusCreated, resp[0])
+}
+
+// Unrechirp handles DELETE /api/chirps/{chirpID}/rechirp, where chirpID is
+// the chirp that was rechirped
+func (cfg *ApiConfig) Unrechirp(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+
+	deleted, err := cfg.DB.DeleteRechirp(r.Context(), database.DeleteRechirpParams{
+		UserID:     userId,
+		OriginalID: uuid.NullUUID{UUID: chirpId, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error deleting rechirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to undo rechirp")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "rechirp not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attachOriginals embeds the chirp each rechirp or quote points at. An
+// original that was deleted is reported as unavailable.
+func (cfg *ApiConfig) attachOriginals(ctx context.Context, chirps []chirpResponse) error {
+	var ids []uuid.UUID
+	for _, chirp := range chirps {
+		if chirp.originalID.Valid {
+			ids = append(ids, chirp.originalID.UUID)
+		}
+	}
+	originals := map[uuid.UUID]database.Chirp{}
+	if len(ids) > 0 {
+		rows, err := cfg.DB.GetChirpsByIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			originals[row.ID] = row
+		}
+	}
+
+	for i := range chirps {
+		if chirps[i].Kind == chirpKindChirp {
+			continue
+		}
+		original, ok := originals[chirps[i].originalID.UUID]
+		if !chirps[i].originalID.Valid || !ok || original.DeletedAt.Valid {
+			chirps[i].OriginalUnavailable = true
+			continue
+		}
+		embedded := newChirpResponse(original)
+		chirps[i].Original = &embedded
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRechirpRequest(t *testing.T, cfg *ApiConfig, chirpId, userId uuid.UUID) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/chirps/"+chirpId.String()+"/rechirp", nil)
+	r.SetPathValue("chirpID", chirpId.String())
+	return authorize(t, cfg, r, userId)
+}
+
+// fakeChirps makes GetChirp and GetChirpsByIDs answer from chirps
+func fakeChirps(db *fakeDB, chirps ...database.Chirp) {
+	byID := map[string]database.Chirp{}
+	rows := make([]interface{}, 0, len(chirps))
+	for _, chirp := range chirps {
+		byID[chirp.ID.String()] = chirp
+		rows = append(rows, chirp)
+	}
+	db.onFunc("GetChirp", func(args []driver.Value) ([][]driver.Value, error) {
+		chirp, ok := byID[args[0].(string)]
+		if !ok {
+			return nil, nil
+		}
+		return [][]driver.Value{rowValues(chirp)}, nil
+	})
+	db.on("GetChirpsByIDs", rows...)
+}
+
+func newTestChirp(kind string, original uuid.UUID) database.Chirp {
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, UserID: uuid.NullUUID{UUID: uuid.New(), Valid: true}, Kind: kind}
+	if kind == chirpKindChirp {
+		chirp.Body = "original body"
+	} else {
+		chirp.OriginalID = uuid.NullUUID{UUID: original, Valid: true}
+	}
+	return chirp
+}
+
+func TestRechirpOfRechirpPointsAtOriginal(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	db.on("GetUserByID", database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Role: "user"})
+	original := newTestChirp(chirpKindChirp, uuid.Nil)
+	rechirp := newTestChirp(chirpKindRechirp, original.ID)
+	fakeChirps(db, original, rechirp)
+	userId := uuid.New()
+	db.on("CreateChirp", database.Chirp{
+		ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, UserID: uuid.NullUUID{UUID: userId, Valid: true},
+		Kind: chirpKindRechirp, OriginalID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+
+	w := httptest.NewRecorder()
+	cfg.Rechirp(w, newRechirpRequest(t, cfg, rechirp.ID, userId))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	calls := db.called("CreateChirp")
+	require.Len(t, calls, 1)
+	// body, user_id, parent_id, root_id, kind, original_id
+	assert.Equal(t, chirpKindRechirp, calls[0].Args[4])
+	assert.Equal(t, original.ID.String(), calls[0].Args[5])
+
+	var resp chirpResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.Original)
+	assert.Equal(t, original.ID, resp.Original.ID)
+	assert.Equal(t, "original body", resp.Original.Body)
+}
+
+func TestRechirpTwiceConflicts(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	db.on("GetUserByID", database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Role: "user"})
+	original := newTestChirp(chirpKindChirp, uuid.Nil)
+	fakeChirps(db, original)
+	// chirps_one_rechirp_per_user_idx refuses a second rechirp of the same chirp
+	db.onFunc("CreateChirp", func([]driver.Value) ([][]driver.Value, error) {
+		return nil, &pq.Error{Code: "23505"}
+	})
+
+	w := httptest.NewRecorder()
+	cfg.Rechirp(w, newRechirpRequest(t, cfg, original.ID, uuid.New()))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestRechirpDeletedChirp(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	db.on("GetUserByID", database.User{ID: uuid.New(), CreatedAt: fakeNow, UpdatedAt: fakeNow, Role: "user"})
+	original := newTestChirp(chirpKindChirp, uuid.Nil)
+	original.DeletedAt = sql.NullTime{Time: fakeNow, Valid: true}
+	fakeChirps(db, original)
+
+	w := httptest.NewRecorder()
+	cfg.Rechirp(w, newRechirpRequest(t, cfg, original.ID, uuid.New()))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, db.called("CreateChirp"))
+}
+
+func TestAttachOriginals(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	original := newTestChirp(chirpKindChirp, uuid.Nil)
+	deleted := newTestChirp(chirpKindChirp, uuid.Nil)
+	deleted.DeletedAt = sql.NullTime{Time: fakeNow, Valid: true}
+	fakeChirps(db, original, deleted)
+
+	quote := newTestChirp(chirpKindQuote, original.ID)
+	quote.Body = "look at this"
+	chirps := []chirpResponse{
+		newChirpResponse(quote),
+		newChirpResponse(newTestChirp(chirpKindRechirp, deleted.ID)),
+		newChirpResponse(newTestChirp(chirpKindRechirp, uuid.New())),
+		newChirpResponse(original),
+	}
+	require.NoError(t, cfg.attachOriginals(context.Background(), chirps))
+
+	require.NotNil(t, chirps[0].Original)
+	assert.Equal(t, original.ID, chirps[0].Original.ID)
+	assert.Equal(t, "look at this", chirps[0].Body)
+	assert.False(t, chirps[0].OriginalUnavailable)
+
+	// deleted or missing originals aren't embedded
+	for _, chirp := range chirps[1:3] {
+		assert.Nil(t, chirp.Original)
+		assert.True(t, chirp.OriginalUnavailable)
+	}
+
+	assert.Nil(t, chirps[3].Original)
+	assert.False(t, chirps[3].OriginalUnavailable)
+}
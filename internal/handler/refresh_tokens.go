@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// refreshTokenTTL is how long a refresh token stays usable if it is never
+// rotated
+const refreshTokenTTL = 60 * 24 * time.Hour
+
+var (
+	errRefreshTokenInvalid = errors.New("invalid refresh token")
+	// errRefreshTokenReused means a token that was already rotated or
+	// revoked came back, so someone else may hold a copy
+	errRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// checkRefreshToken decides whether a stored refresh token can be exchanged
+func checkRefreshToken(token database.RefreshToken, now time.Time) error {
+	if token.RevokedAt.Valid {
+		return errRefreshTokenReused
+	}
+	if !now.Before(token.ExpiresAt) {
+		return errRefreshTokenInvalid
+	}
+	return nil
+}
+
+// hashToken is the form opaque tokens such as refresh tokens are stored
+// and looked up in
+func (cfg *ApiConfig) hashToken(token string) string {
+	key := cfg.RefreshTokenKey
+	if key == "" {
+		key = cfg.Secret
+	}
+	return auth.HashRefreshToken(token, key)
+}
+
+// issueRefreshToken saves a new refresh token for userId in familyId and
+// returns it. Only its hash is stored.
+func (cfg *ApiConfig) issueRefreshToken(ctx context.Context, q *database.Queries, userId, familyId uuid.UUID) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = q.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash: cfg.hashToken(token),
+		UserID:    userId,
+		FamilyID:  familyId,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// rotateRefreshToken exchanges presented for a new token in the same
+// family and records meta as the session's latest use. Presenting a token
+// that was already rotated or revoked revokes its whole family, logging out
+// whoever holds the current token too. It returns the user, the session and
+// the new token.
+func (cfg *ApiConfig) rotateRefreshToken(ctx context.Context, presented string, meta sessionMeta) (uuid.UUID, uuid.UUID, string, error) {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	stored, err := qtx.GetRefreshTokenForUpdate(ctx, cfg.hashToken(presented))
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, uuid.Nil, "", errRefreshTokenInvalid
+	}
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", err
+	}
+
+	if err := checkRefreshToken(stored, time.Now()); err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			if err := qtx.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+				return uuid.Nil, uuid.Nil, "", err
+			}
+			_, err := qtx.RevokeSession(ctx, database.RevokeSessionParams{ID: stored.FamilyID, UserID: stored.UserID})
+			if err != nil {
+				return uuid.Nil, uuid.Nil, "", err
+			}
+			if err := tx.Commit(); err != nil {
+				return uuid.Nil, uuid.Nil, "", err
+			}
+		}
+		return uuid.Nil, uuid.Nil, "", err
+	}
+
+	next, err := cfg.issueRefreshToken(ctx, qtx, stored.UserID, stored.FamilyID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", err
+	}
+	err = qtx.RotateRefreshToken(ctx, database.RotateRefreshTokenParams{
+		TokenHash:  stored.TokenHash,
+		ReplacedBy: sql.NullString{String: cfg.hashToken(next), Valid: true},
+	})
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", err
+	}
+	err = qtx.TouchSession(ctx, database.TouchSessionParams{
+		ID:        stored.FamilyID,
+		UserAgent: meta.UserAgent,
+		Ip:        meta.IP,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return uuid.Nil, uuid.Nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, uuid.Nil, "", err
+	}
+	return stored.UserID, stored.FamilyID, next, nil
+}
+
+// legacyRefreshTokenBatch is how many plaintext rows are re-hashed per query
+const legacyRefreshTokenBatch = 500
+
+// HashLegacyRefreshTokens replaces refresh tokens stored before hashing was
+// introduced with their hashes. Run it before serving requests; until it has
+// run, those tokens are rejected.
+func (cfg *ApiConfig) HashLegacyRefreshTokens(ctx context.Context) (int, error) {
+	upgraded := 0
+	for {
+		rows, err := cfg.DB.ListLegacyRefreshTokens(ctx, legacyRefreshTokenBatch)
+		if err != nil {
+			return upgraded, err
+		}
+		if len(rows) == 0 {
+			return upgraded, nil
+		}
+		for _, row := range rows {
+			replacedBy := row.ReplacedBy
+			if replacedBy.Valid {
+				replacedBy.String = cfg.hashToken(replacedBy.String)
+			}
+			err := cfg.DB.UpgradeRefreshTokenHash(ctx, database.UpgradeRefreshTokenHashParams{
+				NewTokenHash: cfg.hashToken(row.TokenHash),
+				ReplacedBy:   replacedBy,
+				OldTokenHash: row.TokenHash,
+			})
+			if err != nil {
+				return upgraded, err
+			}
+			upgraded++
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRefreshToken(t *testing.T) {
+	now := time.Now()
+	live := database.RefreshToken{ExpiresAt: now.Add(time.Hour)}
+	assert.NoError(t, checkRefreshToken(live, now))
+
+	expired := database.RefreshToken{ExpiresAt: now.Add(-time.Second)}
+	assert.ErrorIs(t, checkRefreshToken(expired, now), errRefreshTokenInvalid)
+
+	rotated := database.RefreshToken{
+		ExpiresAt:  now.Add(time.Hour),
+		RevokedAt:  sql.NullTime{Time: now.Add(-time.Minute), Valid: true},
+		ReplacedBy: sql.NullString{String: "next", Valid: true},
+	}
+	assert.ErrorIs(t, checkRefreshToken(rotated, now), errRefreshTokenReused)
+
+	// a reused token is treated as theft even after it would have expired
+	rotated.ExpiresAt = now.Add(-time.Hour)
+	assert.ErrorIs(t, checkRefreshToken(rotated, now), errRefreshTokenReused)
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// chirpEditWindow is how long after posting a chirp can be edited.
+// Chirpy Red members can edit at any time.
+const chirpEditWindow = 15 * time.Minute
+
+// EditChirp handles PUT /api/chirps/{chirpID}. The previous body is kept
+// in chirp_revisions before the new one replaces it.
+func (cfg *ApiConfig) EditChirp(w http.ResponseWriter, r *http.Request) {
+	userId, err := cfg.authenticatedUserID(r, auth.ScopeChirpsWrite)
+	if err != nil {
+		respondWithAuthError(w, err)
+		return
+	}
+	if !cfg.requireVerifiedEmail(w, r, userId, restrictChirp) {
+		return
+	}
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	moderated, err := cfg.moderateChirp(params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to edit chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), chirpId)
+	if err != nil || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	if chirp.UserID != (uuid.NullUUID{UUID: userId, Valid: true}) {
+		respondWithError(w, http.StatusForbidden, "you can only edit your chirp")
+		return
+	}
+	if chirp.Kind == chirpKindRechirp {
+		respondWithError(w, http.StatusBadRequest, "rechirps have no body to edit")
+		return
+	}
+	if !user.IsChirpyRed && time.Since(chirp.CreatedAt) > chirpEditWindow {
+		respondWithError(w, http.StatusForbidden, "the edit window for this chirp has closed")
+		return
+	}
+
+	err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
+	})
+	if err != nil {
+		log.Printf("Error saving chirp revision: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to edit chirp")
+		return
+	}
+	edited, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: moderated.Body,
+	})
+	if err != nil {
+		log.Printf("Error updating chirp: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to edit chirp")
+		return
+	}
+	if err := qtx.DeleteChirpHashtags(r.Context(), chirp.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to edit chirp")
+		return
+	}
+	if err := saveChirpHashtags(r.Context(), qtx, edited); err != nil {
+		log.Printf("Error saving hashtags: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to edit chirp")
+		return
+	}
+	if err := saveModerationFlags(r.Context(), qtx, edited.ID, moderated); err != nil {
+		log.Printf("Error saving moderation flags: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to edit chirp")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to edit chirp")
+		return
+	}
+
+	resp := []chirpResponse{newChirpResponse(edited)}
+	if err := cfg.decorateChirps(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, resp); err != nil {
+		log.Printf("Error decorating chirp: %v", err)
+	}
+	respondWithJSON(w, http.StatusOK, resp[0])
+}
+
+// GetChirpRevisions handles GET /api/chirps/{chirpID}/revisions, listing
+// earlier bodies of the chirp newest first
+func (cfg *ApiConfig) GetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+	chirp, err := cfg.DB.GetChirp(r.Context(), chirpId)
+	if err != nil || chirp.DeletedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+
+	revisions, err := cfg.DB.ListChirpRevisions(r.Context(), chirpId)
+	if err != nil {
+		log.Printf("Error listing chirp revisions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting revisions")
+		return
+	}
+
+	type revisionResponse struct {
+		ID        uuid.UUID `json:"id"`
+		Body      string    `json:"body"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	resp := make([]revisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		resp = append(resp, revisionResponse{
+			ID:        revision.ID,
+			Body:      revision.Body,
+			CreatedAt: revision.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
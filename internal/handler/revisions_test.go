@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEditRequest(t *testing.T, cfg *ApiConfig, chirp database.Chirp, userId uuid.UUID) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/api/chirps/"+chirp.ID.String(), strings.NewReader(`{"body":"edited body"}`))
+	r.SetPathValue("chirpID", chirp.ID.String())
+	return authorize(t, cfg, r, userId)
+}
+
+func newEditFixtures(age time.Duration, red bool) (database.User, database.Chirp) {
+	user := database.User{
+		ID:              uuid.New(),
+		CreatedAt:       fakeNow,
+		UpdatedAt:       fakeNow,
+		Email:           "walt@breakingbad.com",
+		IsChirpyRed:     red,
+		EmailVerifiedAt: sql.NullTime{Time: fakeNow, Valid: true},
+		Role:            "user",
+	}
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: fakeNow.Add(-age),
+		UpdatedAt: fakeNow.Add(-age),
+		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
+		Body:      "original body",
+		Kind:      chirpKindChirp,
+	}
+	return user, chirp
+}
+
+func TestEditChirpWindow(t *testing.T) {
+	tests := map[string]struct {
+		age  time.Duration
+		red  bool
+		code int
+	}{
+		"inside window":       {time.Minute, false, http.StatusOK},
+		"window closed":       {chirpEditWindow + time.Minute, false, http.StatusForbidden},
+		"red past the window": {24 * time.Hour, true, http.StatusOK},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, db := newFakeConfig(t)
+			user, chirp := newEditFixtures(tt.age, tt.red)
+			edited := chirp
+			edited.Body = "edited body"
+			edited.EditedAt = sql.NullTime{Time: fakeNow, Valid: true}
+			db.on("GetUserByID", user)
+			db.on("GetChirpForUpdate", chirp)
+			db.on("UpdateChirpBody", edited)
+
+			w := httptest.NewRecorder()
+			cfg.EditChirp(w, newEditRequest(t, cfg, chirp, user.ID))
+			require.Equal(t, tt.code, w.Code, w.Body.String())
+			if tt.code != http.StatusOK {
+				assert.Empty(t, db.called("CreateChirpRevision"))
+				assert.Empty(t, db.called("UpdateChirpBody"))
+				return
+			}
+
+			// the old body is kept as a revision
+			revisions := db.called("CreateChirpRevision")
+			require.Len(t, revisions, 1)
+			assert.Equal(t, []driver.Value{chirp.ID.String(), "original body"}, revisions[0].Args)
+			var resp chirpResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, "edited body", resp.Body)
+		})
+	}
+}
+
+func TestEditChirpRequiresVerifiedEmail(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	cfg.UnverifiedRestrictions = map[string]bool{restrictChirp: true}
+	user, chirp := newEditFixtures(time.Minute, false)
+	user.EmailVerifiedAt = sql.NullTime{}
+	db.on("GetUserByID", user)
+	db.on("GetChirpForUpdate", chirp)
+
+	w := httptest.NewRecorder()
+	cfg.EditChirp(w, newEditRequest(t, cfg, chirp, user.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, db.called("UpdateChirpBody"))
+}
+
+func TestEditChirpRefusesSuspended(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	user, chirp := newEditFixtures(time.Minute, false)
+	user.SuspendedAt = sql.NullTime{Time: fakeNow, Valid: true}
+	db.on("GetUserByID", user)
+	db.on("GetChirpForUpdate", chirp)
+
+	w := httptest.NewRecorder()
+	cfg.EditChirp(w, newEditRequest(t, cfg, chirp, user.ID))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, db.called("UpdateChirpBody"))
+}
+
+func TestGetChirpRevisions(t *testing.T) {
+	cfg, db := newFakeConfig(t)
+	_, chirp := newEditFixtures(time.Hour, false)
+	db.on("GetChirp", chirp)
+	db.on("ListChirpRevisions",
+		database.ChirpRevision{ID: uuid.New(), ChirpID: chirp.ID, Body: "second", CreatedAt: fakeNow},
+		database.ChirpRevision{ID: uuid.New(), ChirpID: chirp.ID, Body: "first", CreatedAt: fakeNow.Add(-time.Minute)},
+	)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/chirps/"+chirp.ID.String()+"/revisions", nil)
+	r.SetPathValue("chirpID", chirp.ID.String())
+	w := httptest.NewRecorder()
+	cfg.GetChirpRevisions(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp []struct {
+		Body string `json:"body"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Len(t, resp, 2)
+	assert.Equal(t, "second", resp[0].Body)
+	assert.Equal(t, "first", resp[1].Body)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type contextKey int
+
+// staffUserKey holds the id of the user RequireRole let through
+const staffUserKey contextKey = iota
+
+// RequireRole only lets requests through to next from users with role or a
+// more privileged one. The role in the access token is checked first, then
+// the one in the database, so a user who has been demoted loses access
+// straight away rather than when their token expires. Only tokens from
+// logging in are accepted: personal access tokens and OAuth access tokens
+// are refused, whatever their owner's role, and so are suspended users.
+func (cfg *ApiConfig) RequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil || auth.IsPersonalAccessToken(token) {
+			respondWithError(w, http.StatusUnauthorized, "invalid access token")
+			return
+		}
+		claims, err := cfg.parseAccessToken(token)
+		if err != nil || claims.ClientID != "" {
+			respondWithError(w, http.StatusUnauthorized, "invalid access token")
+			return
+		}
+		if !auth.RoleAtLeast(claims.Role, role) {
+			respondWithError(w, http.StatusForbidden, "requires the "+role+" role")
+			return
+		}
+		user, err := cfg.DB.GetUserByID(r.Context(), claims.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusUnauthorized, "invalid access token")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "failed to check role")
+			return
+		}
+		if !auth.RoleAtLeast(user.Role, role) {
+			respondWithError(w, http.StatusForbidden, "requires the "+role+" role")
+			return
+		}
+		if suspended(user, time.Now()) {
+			respondSuspended(w, user)
+			return
+		}
+		ctx := context.WithValue(r.Context(), staffUserKey, user.ID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// staffUserID returns the user RequireRole let the request through for
+func staffUserID(r *http.Request) uuid.UUID {
+	userId, _ := r.Context().Value(staffUserKey).(uuid.UUID)
+	return userId
+}
+
+// UpdateUserRole handles PUT /admin/users/{id}/role. Admins can't change
+// their own role, so the last admin can't lock everyone out by accident.
+// The new role is in the user's access tokens from their next refresh and
+// applies to RequireRole at once.
+func (cfg *ApiConfig) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+	type respBody struct {
+		ID        uuid.UUID `json:"id"`
+		Email     string    `json:"email"`
+		Role      string    `json:"role"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	userId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !auth.ValidRole(params.Role) {
+		respondWithError(w, http.StatusBadRequest, "unknown role")
+		return
+	}
+	if userId == staffUserID(r) {
+		respondWithError(w, http.StatusForbidden, "you can't change your own role")
+		return
+	}
+
+	user, err := cfg.DB.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{ID: userId, Role: params.Role})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to update role")
+		return
+	}
+	log.Printf("User %s set the role of %s to %s", staffUserID(r), user.ID, user.Role)
+	respondWithJSON(w, http.StatusOK, respBody{ID: user.ID, Email: user.Email, Role: user.Role, UpdatedAt: user.UpdatedAt})
+}
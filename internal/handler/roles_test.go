@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireRoleRejects(t *testing.T) {
+	cfg := &ApiConfig{Secret: "secret"}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("request reached the protected handler")
+	})
+	h := cfg.RequireRole(auth.RoleModerator, next)
+
+	sign := func(claims auth.AccessClaims) string {
+		claims.UserID = uuid.New()
+		token, err := cfg.signAccessToken(claims, time.Hour)
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+	tests := map[string]struct {
+		header string
+		code   int
+	}{
+		"no token":      {"", http.StatusUnauthorized},
+		"bad token":     {"Bearer nonsense", http.StatusUnauthorized},
+		"personal":      {"Bearer chirpy_pat_0123456789abcdef", http.StatusUnauthorized},
+		"oauth client":  {sign(auth.AccessClaims{Role: auth.RoleAdmin, ClientID: "app"}), http.StatusUnauthorized},
+		"user role":     {sign(auth.AccessClaims{Role: auth.RoleUser}), http.StatusForbidden},
+		"no role claim": {sign(auth.AccessClaims{}), http.StatusForbidden},
+		"unknown role":  {sign(auth.AccessClaims{Role: "root"}), http.StatusForbidden},
+	}
+	for name, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/admin/moderation/flags", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, tt.code, w.Code, name)
+	}
+}
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type searchResultResponse struct {
+	chirpResponse
+	Rank float32 `json:"rank"`
+	// Snippet is HTML: the body, escaped, with matches in <mark> tags
+	Snippet string `json:"snippet"`
+}
+
+// SearchChirps handles GET /api/chirps/search?q=. Results are ordered by
+// relevance unless sort=asc|desc asks for chronological order, and page
+// the same way GET /api/chirps does.
+func (cfg *ApiConfig) SearchChirps(w http.ResponseWriter, r *http.Request) {
+	tsQuery, err := buildTSQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sort := strings.ToLower(r.URL.Query().Get("sort"))
+	if sort == "" || sort == "rank" {
+		cfg.searchChirpsByRank(w, r, tsQuery)
+		return
+	}
+
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.nullCursor()
+	var rows []database.SearchChirpsAscRow
+	if page.Desc {
+		var descRows []database.SearchChirpsDescRow
+		descRows, err = cfg.DB.SearchChirpsDesc(r.Context(), database.SearchChirpsDescParams{
+			Query:           tsQuery,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+		for _, row := range descRows {
+			rows = append(rows, database.SearchChirpsAscRow(row))
+		}
+	} else {
+		rows, err = cfg.DB.SearchChirpsAsc(r.Context(), database.SearchChirpsAscParams{
+			Query:           tsQuery,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           page.Limit + 1,
+		})
+	}
+	if err != nil {
+		log.Printf("Error searching chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred searching chirps")
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > int(page.Limit) {
+		rows = rows[:page.Limit]
+		last := rows[len(rows)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	results := make([]database.SearchChirpsByRankRow, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, database.SearchChirpsByRankRow(row))
+	}
+	cfg.respondWithSearchResults(w, r, results, nextCursor)
+}
+
+func (cfg *ApiConfig) searchChirpsByRank(w http.ResponseWriter, r *http.Request, tsQuery string) {
+	limit, err := parsePageLimit(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	params := database.SearchChirpsByRankParams{
+		Query: tsQuery,
+		Limit: limit + 1,
+	}
+	if rawCursor := r.URL.Query().Get("cursor"); rawCursor != "" {
+		rank, cursor, err := decodeRankCursor(rawCursor)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		params.CursorRank = sql.NullFloat64{Float64: float64(rank), Valid: true}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	rows, err := cfg.DB.SearchChirpsByRank(r.Context(), params)
+	if err != nil {
+		log.Printf("Error searching chirps: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred searching chirps")
+		return
+	}
+
+	nextCursor := ""
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		nextCursor = encodeRankCursor(last.Rank, last.CreatedAt, last.ID)
+	}
+
+	cfg.respondWithSearchResults(w, r, rows, nextCursor)
+}
+
+// respondWithSearchResults sends a page of search results, decorated like
+// every other chirp listing
+func (cfg *ApiConfig) respondWithSearchResults(w http.ResponseWriter, r *http.Request, rows []database.SearchChirpsByRankRow, nextCursor string) {
+	chirps := make([]chirpResponse, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, newChirpResponse(chirpFromSearchRow(row)))
+	}
+	if err := cfg.decorateChirps(r.Context(), cfg.optionalUserID(r), chirps); err != nil {
+		log.Printf("Error decorating search results: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred searching chirps")
+		return
+	}
+
+	resp := make([]searchResultResponse, 0, len(rows))
+	for i, row := range rows {
+		resp = append(resp, searchResultResponse{chirpResponse: chirps[i], Rank: row.Rank, Snippet: row.Snippet})
+	}
+	setNextPage(w, r, nextCursor)
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// chirpFromSearchRow picks the chirp's own columns out of a search row
+func chirpFromSearchRow(row database.SearchChirpsByRankRow) database.Chirp {
+	return database.Chirp{
+		ID:         row.ID,
+		CreatedAt:  row.CreatedAt,
+		UpdatedAt:  row.UpdatedAt,
+		UserID:     row.UserID,
+		Body:       row.Body,
+		ParentID:   row.ParentID,
+		ReplyCount: row.ReplyCount,
+		Kind:       row.Kind,
+		OriginalID: row.OriginalID,
+		EditedAt:   row.EditedAt,
+	}
+}
+
+// buildTSQuery turns the user's search string into a to_tsquery expression.
+// Terms are ANDed together, "quoted phrases" must match in order and a
+// trailing * makes a term a prefix match. Anything that is not a letter or
+// digit is dropped so user input can never produce tsquery syntax errors.
+func buildTSQuery(q string) (string, error) {
+	var terms []string
+	rest := strings.TrimSpace(q)
+	for rest != "" {
+		var raw string
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				raw, rest = rest[1:], ""
+			} else {
+				raw, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				raw, rest = rest, ""
+			} else {
+				raw, rest = rest[:end], rest[end:]
+			}
+		}
+		rest = strings.TrimSpace(rest)
+
+		var words []string
+		for _, field := range strings.Fields(raw) {
+			prefix := strings.HasSuffix(field, "*")
+			parts := strings.FieldsFunc(field, func(c rune) bool {
+				return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+			})
+			if len(parts) == 0 {
+				continue
+			}
+			if prefix {
+				parts[len(parts)-1] += ":*"
+			}
+			words = append(words, parts...)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		if len(words) == 1 {
+			terms = append(terms, words[0])
+			continue
+		}
+
+		// quoted phrases and words split on punctuation, e.g. don't, match in order
+		terms = append(terms, "("+strings.Join(words, " <-> ")+")")
+	}
+
+	if len(terms) == 0 {
+		return "", errors.New("search query is empty")
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+func encodeRankCursor(rank float32, createdAt time.Time, id uuid.UUID) string {
+	raw := fmt.Sprintf("%s|%s|%s",
+		strconv.FormatFloat(float64(rank), 'g', -1, 32),
+		createdAt.UTC().Format(time.RFC3339Nano),
+		id,
+	)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeRankCursor(s string) (float32, pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, pageCursor{}, errors.New("invalid cursor")
+	}
+	rankStr, rest, found := strings.Cut(string(raw), "|")
+	if !found {
+		return 0, pageCursor{}, errors.New("invalid cursor")
+	}
+	rank, err := strconv.ParseFloat(rankStr, 32)
+	if err != nil {
+		return 0, pageCursor{}, errors.New("invalid cursor")
+	}
+	cursor, err := decodeCursor(base64.RawURLEncoding.EncodeToString([]byte(rest)))
+	if err != nil {
+		return 0, pageCursor{}, err
+	}
+	return float32(rank), cursor, nil
+}
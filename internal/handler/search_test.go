@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTSQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"chirpy", "chirpy"},
+		{"hello world", "hello & world"},
+		{`"good morning" chirp*`, "(good <-> morning) & chirp:*"},
+		{"fornax!", "fornax"},
+		{"don't", "(don <-> t)"},
+		{`"unterminated phrase`, "(unterminated <-> phrase)"},
+		{"a & b | !c", "a & b & c"},
+	}
+	for _, tt := range tests {
+		got, err := buildTSQuery(tt.input)
+		assert.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, got, tt.input)
+	}
+
+	_, err := buildTSQuery("  !!! ")
+	assert.Error(t, err)
+}
+
+func TestRankCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 6, 5, 10, 15, 0, 0, time.UTC)
+	id := uuid.New()
+
+	rank, cursor, err := decodeRankCursor(encodeRankCursor(0.0607927, createdAt, id))
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.0607927), rank)
+	assert.True(t, createdAt.Equal(cursor.CreatedAt))
+	assert.Equal(t, id, cursor.ID)
+}
+
+func TestChirpFromSearchRowKeepsEdits(t *testing.T) {
+	editedAt := time.Date(2025, 6, 5, 11, 0, 0, 0, time.UTC)
+	row := database.SearchChirpsByRankRow{
+		ID:       uuid.New(),
+		Body:     "hello <b>world</b>",
+		Kind:     "chirp",
+		EditedAt: sql.NullTime{Time: editedAt, Valid: true},
+		Snippet:  "hello &lt;b&gt;<mark>world</mark>&lt;/b&gt;",
+	}
+	resp := newChirpResponse(chirpFromSearchRow(row))
+	assert.True(t, resp.Edited)
+	assert.Equal(t, &editedAt, resp.EditedAt)
+	assert.Equal(t, row.Body, resp.Body)
+}
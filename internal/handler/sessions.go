@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Glenn444/chirpy/internal/auth"
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxUserAgentLength keeps clients from storing arbitrary amounts of text
+const maxUserAgentLength = 512
+
+// sessionMeta describes the device a request came from
+type sessionMeta struct {
+	UserAgent string
+	IP        string
+}
+
+func (cfg *ApiConfig) requestMeta(r *http.Request) sessionMeta {
+	// Postgres only stores valid UTF-8, so bad bytes are replaced first
+	userAgent := truncateUTF8(strings.ToValidUTF8(r.UserAgent(), "\uFFFD"), maxUserAgentLength)
+	return sessionMeta{UserAgent: userAgent, IP: cfg.clientIP(r)}
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// clientIP is the address of the caller. X-Forwarded-For is only believed
+// when the server is configured to run behind a proxy.
+func (cfg *ApiConfig) clientIP(r *http.Request) string {
+	if cfg.TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authenticatedSession returns the user and session behind the request's
+// bearer access token (uuid.Nil for older tokens). Personal access tokens
+// and OAuth access tokens are refused: account security settings need a
+// real login. So are suspended users.
+func (cfg *ApiConfig) authenticatedSession(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	userId, sessionId, err := cfg.validateAccessToken(token)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return userId, sessionId, cfg.checkNotSuspended(r.Context(), userId)
+}
+
+// startSession records a new login from the device behind r and returns
+// the session with its first refresh token
+func (cfg *ApiConfig) startSession(ctx context.Context, r *http.Request, userId uuid.UUID) (database.Session, string, error) {
+	tx, err := cfg.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Session{}, "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	meta := cfg.requestMeta(r)
+	session, err := qtx.CreateSession(ctx, database.CreateSessionParams{
+		UserID:    userId,
+		UserAgent: meta.UserAgent,
+		Ip:        meta.IP,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return database.Session{}, "", err
+	}
+	refreshToken, err := cfg.issueRefreshToken(ctx, qtx, userId, session.ID)
+	if err != nil {
+		return database.Session{}, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return database.Session{}, "", err
+	}
+	return session, refreshToken, nil
+}
+
+// revokeOtherSessions logs userId out of every session except keepId,
+// which may be uuid.Nil to log out of all of them
+func revokeOtherSessions(ctx context.Context, q *database.Queries, userId, keepId uuid.UUID) error {
+	err := q.RevokeOtherSessions(ctx, database.RevokeOtherSessionsParams{UserID: userId, KeepID: keepId})
+	if err != nil {
+		return err
+	}
+	return q.RevokeOtherRefreshTokens(ctx, database.RevokeOtherRefreshTokensParams{UserID: userId, KeepID: keepId})
+}
+
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+// GetSessions handles GET /api/sessions, listing the caller's active
+// logins, most recently used first
+func (cfg *ApiConfig) GetSessions(w http.ResponseWriter, r *http.Request) {
+	userId, sessionId, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	sessions, err := cfg.DB.ListActiveSessions(r.Context(), userId)
+	if err != nil {
+		log.Printf("Error listing sessions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting sessions")
+		return
+	}
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, sessionResponse{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.Ip,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			Current:    s.ID == sessionId,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// DeleteSession handles DELETE /api/sessions/{id}. The session's refresh
+// token stops working at once; access tokens already issued to it run out
+// on their own within the hour.
+func (cfg *ApiConfig) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	userId, _, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	sessionId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
+	revoked, err := qtx.RevokeSession(r.Context(), database.RevokeSessionParams{ID: sessionId, UserID: userId})
+	if err != nil {
+		log.Printf("Error revoking session: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err := qtx.RevokeRefreshTokenFamily(r.Context(), sessionId); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteOtherSessions handles DELETE /api/sessions: log out everywhere
+// except the session the request's access token belongs to
+func (cfg *ApiConfig) DeleteOtherSessions(w http.ResponseWriter, r *http.Request) {
+	userId, sessionId, err := cfg.authenticatedSession(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
+		return
+	}
+	if sessionId == uuid.Nil {
+		respondWithError(w, http.StatusBadRequest, "access token has no session, log in again first")
+		return
+	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	defer tx.Rollback()
+	if err := revokeOtherSessions(r.Context(), cfg.DB.WithTx(tx), userId, sessionId); err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
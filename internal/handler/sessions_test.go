@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMeta(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/login", nil)
+	r.RemoteAddr = "203.0.113.7:51234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.1")
+	r.Header.Set("User-Agent", strings.Repeat("a", 600))
+
+	cfg := &ApiConfig{}
+	meta := cfg.requestMeta(r)
+	assert.Equal(t, "203.0.113.7", meta.IP)
+	assert.Len(t, meta.UserAgent, maxUserAgentLength)
+
+	cfg.TrustProxyHeaders = true
+	assert.Equal(t, "198.51.100.1", cfg.clientIP(r))
+
+	r.Header.Set("X-Forwarded-For", "not an ip")
+	assert.Equal(t, "203.0.113.7", cfg.clientIP(r))
+
+	// a character across the limit is dropped whole
+	r.Header.Set("User-Agent", strings.Repeat("a", maxUserAgentLength-1)+"é")
+	meta = cfg.requestMeta(r)
+	assert.Equal(t, strings.Repeat("a", maxUserAgentLength-1), meta.UserAgent)
+
+	r.Header.Set("User-Agent", "curl\xff/8.0")
+	meta = cfg.requestMeta(r)
+	assert.True(t, utf8.ValidString(meta.UserAgent))
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "héllo", truncateUTF8("héllo", 10))
+	assert.Equal(t, "h", truncateUTF8("héllo", 2))
+	assert.Equal(t, "hé", truncateUTF8("héllo", 3))
+	assert.Equal(t, "", truncateUTF8("日本", 2))
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultThreadDepth = 10
+	maxThreadDepth     = 50
+	// maxThreadSize caps how many chirps of one conversation are loaded
+	maxThreadSize = 500
+)
+
+// threadNode is one chirp in a conversation tree. Deleted chirps that still
+// have replies are kept as tombstones with an empty body.
+type threadNode struct {
+	chirpResponse
+	Deleted bool          `json:"deleted"`
+	Replies []*threadNode `json:"replies"`
+}
+
+// GetChirpThread handles GET /api/chirps/{chirpID}/thread. It returns the
+// whole conversation the chirp belongs to, starting from its root. depth
+// limits how many levels of replies are included and sort=asc|desc orders
+// replies under each chirp (oldest first by default).
+func (cfg *ApiConfig) GetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id")
+		return
+	}
+
+	depth := defaultThreadDepth
+	if rawDepth := r.URL.Query().Get("depth"); rawDepth != "" {
+		depth, err = strconv.Atoi(rawDepth)
+		if err != nil || depth < 0 {
+			respondWithError(w, http.StatusBadRequest, "depth must be a non-negative integer")
+			return
+		}
+		depth = min(depth, maxThreadDepth)
+	}
+
+	desc := false
+	switch strings.ToLower(r.URL.Query().Get("sort")) {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		respondWithError(w, http.StatusBadRequest, "sort must be asc or desc")
+		return
+	}
+
+	chirp, err := cfg.DB.GetChirp(r.Context(), chirpId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	rootID := chirp.ID
+	if chirp.RootID.Valid {
+		rootID = chirp.RootID.UUID
+	}
+
+	chirps, err := cfg.DB.GetThreadChirps(r.Context(), database.GetThreadChirpsParams{
+		RootID: rootID,
+		Limit:  maxThreadSize,
+	})
+	if err != nil {
+		log.Printf("Error getting thread: %v", err)
+		respondWithError(w, http.StatusInternalServerError, "error occurred getting thread")
+		return
+	}
+
+	root := buildThread(chirps, rootID, depth, desc)
+	if root == nil {
+		respondWithError(w, http.StatusNotFound, "chirp not found")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, root)
+}
+
+// buildThread arranges chirps, which must be ordered oldest first, into a
+// tree under rootID, keeping at most maxDepth levels of replies
+func buildThread(chirps []database.Chirp, rootID uuid.UUID, maxDepth int, desc bool) *threadNode {
+	var root *threadNode
+	children := map[uuid.UUID][]*threadNode{}
+	for _, chirp := range chirps {
+		node := &threadNode{
+			chirpResponse: newChirpResponse(chirp),
+			Deleted:       chirp.DeletedAt.Valid,
+			Replies:       []*threadNode{},
+		}
+		if chirp.ID == rootID {
+			root = node
+			continue
+		}
+		if chirp.ParentID.Valid {
+			children[chirp.ParentID.UUID] = append(children[chirp.ParentID.UUID], node)
+		}
+	}
+	if root == nil {
+		return nil
+	}
+
+	var attach func(node *threadNode, depth int)
+	attach = func(node *threadNode, depth int) {
+		if depth >= maxDepth {
+			return
+		}
+		replies := children[node.ID]
+		if desc {
+			for i, j := 0, len(replies)-1; i < j; i, j = i+1, j-1 {
+				replies[i], replies[j] = replies[j], replies[i]
+			}
+		}
+		for _, reply := range replies {
+			attach(reply, depth+1)
+			node.Replies = append(node.Replies, reply)
+		}
+	}
+	attach(root, 0)
+	return root
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildThread(t *testing.T) {
+	start := time.Date(2025, 6, 17, 9, 0, 0, 0, time.UTC)
+	newChirp := func(minute int, parent *database.Chirp) database.Chirp {
+		chirp := database.Chirp{ID: uuid.New(), CreatedAt: start.Add(time.Duration(minute) * time.Minute)}
+		if parent != nil {
+			chirp.ParentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		}
+		return chirp
+	}
+
+	root := newChirp(0, nil)
+	root.DeletedAt = sql.NullTime{Time: start, Valid: true}
+	first := newChirp(1, &root)
+	second := newChirp(2, &root)
+	nested := newChirp(3, &first)
+	chirps := []database.Chirp{root, first, second, nested}
+
+	tree := buildThread(chirps, root.ID, defaultThreadDepth, false)
+	assert.NotNil(t, tree)
+	assert.True(t, tree.Deleted)
+	assert.Len(t, tree.Replies, 2)
+	assert.Equal(t, first.ID, tree.Replies[0].ID)
+	assert.Equal(t, second.ID, tree.Replies[1].ID)
+	assert.Equal(t, nested.ID, tree.Replies[0].Replies[0].ID)
+
+	tree = buildThread(chirps, root.ID, defaultThreadDepth, true)
+	assert.Equal(t, second.ID, tree.Replies[0].ID)
+
+	tree = buildThread(chirps, root.ID, 1, false)
+	assert.Len(t, tree.Replies, 2)
+	assert.Empty(t, tree.Replies[0].Replies)
+
+	assert.Nil(t, buildThread(chirps[1:], root.ID, defaultThreadDepth, false))
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -33,6 +34,16 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt time.Time `json:"updated_at"`
 		Email     string    `json:"email"`
 		IsChirpyRed bool     `json:"is_chirpy_red"`
+		EmailVerified bool `json:"email_verified"`
+		Role      string    `json:"role"`
+	}
+	email, err := normalizeEmail(params.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !cfg.checkNewPassword(w, params.Password, email) {
+		return
 	}
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
@@ -40,7 +51,7 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	newUser := database.CreateUserParams{
-		Email:          params.Email,
+		Email:          email,
 		HashedPassword: hashedPassword,
 	}
 
@@ -50,6 +61,9 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if err := cfg.startEmailVerification(r.Context(), user.ID, user.Email); err != nil {
+		log.Printf("Error starting email verification: %v", err)
+	}
 
 	resp := respBody{
 		ID:        user.ID,
@@ -57,6 +71,8 @@ func (cfg *ApiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt: user.UpdatedAt,
 		Email:     user.Email,
 		IsChirpyRed: user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Role:         user.Role,
 	}
 
 	successData, err := json.Marshal(resp)
@@ -89,18 +105,13 @@ func (cfg *ApiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 
 		params.Expires_in_seconds = 3600
 	}
-
-	type respBody struct {
-		ID           uuid.UUID `json:"id"`
-		CreatedAt    time.Time `json:"created_at"`
-		UpdatedAt    time.Time `json:"updated_at"`
-		Email        string    `json:"email"`
-		Token        string    `json:"token"`
-		RefreshToken string    `json:"refresh_token"`
-		IsChirpyRed bool `json:"is_chirpy_red"`
+	if !cfg.checkLoginLockout(w, r, params.Email) {
+		return
 	}
-	user, err := cfg.DB.GetUserByEmail(r.Context(), params.Email)
+
+	user, err := cfg.userByEmail(r.Context(), params.Email)
 	if err != nil {
+		cfg.recordLoginFailure(w, r, params.Email)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte("Incorrect email or password"))
@@ -109,36 +120,71 @@ func (cfg *ApiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 	}
 	err = auth.CheckPasswordHash(user.HashedPassword, params.Password)
 	if err != nil {
+		cfg.recordLoginFailure(w, r, params.Email)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusUnauthorized)
 		w.Write([]byte("Incorrect email or password"))
 		fmt.Printf("User not found: %v", err)
 		return
 	}
-
-	token, err := auth.MakeJWT(user.ID, cfg.Secret, params.Expires_in_seconds)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Error generating jwt token"))
+	cfg.rehashPassword(r, user, params.Password)
+	// only someone with the password learns the account is suspended
+	if suspended(user, time.Now()) {
+		respondSuspended(w, user)
 		return
 	}
 
-	refresh_token, err := auth.MakeRefreshToken()
+	mfaRequired, err := cfg.mfaEnabled(r.Context(), user.ID)
 	if err != nil {
-		fmt.Printf("Error generating refreshToken: %v\n", err)
+		fmt.Printf("Error checking two-factor status: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Error logging in")
 		return
 	}
-	expiresAt := time.Now().AddDate(0, 0, 60)
-	newRefreshTokenParams := database.CreateRefreshTokenParams{
-		Token:     refresh_token,
-		UserID:    user.ID,
-		ExpiresAt: expiresAt,
+	// with 2FA on, failures are only cleared once the code is right too
+	if mfaRequired {
+		cfg.startMFAChallenge(w, r, user.ID, params.Expires_in_seconds)
+		return
+	}
+
+	cfg.recordLoginSuccess(r, params.Email)
+	cfg.completeLogin(w, r, user, params.Expires_in_seconds)
+}
+
+// completeLogin starts a session for user and responds with the user and
+// its access and refresh tokens. expiresIn is the access token lifetime in
+// seconds.
+func (cfg *ApiConfig) completeLogin(w http.ResponseWriter, r *http.Request, user database.User, expiresIn time.Duration) {
+	type respBody struct {
+		ID           uuid.UUID `json:"id"`
+		CreatedAt    time.Time `json:"created_at"`
+		UpdatedAt    time.Time `json:"updated_at"`
+		Email        string    `json:"email"`
+		Token        string    `json:"token"`
+		RefreshToken string    `json:"refresh_token"`
+		IsChirpyRed bool `json:"is_chirpy_red"`
+		EmailVerified bool `json:"email_verified"`
+		Role         string    `json:"role"`
+	}
+	// every way of logging in ends here
+	if suspended(user, time.Now()) {
+		respondSuspended(w, user)
+		return
 	}
-	_, err = cfg.DB.CreateRefreshToken(r.Context(), newRefreshTokenParams)
+	session, refresh_token, err := cfg.startSession(r.Context(), r, user.ID)
 	if err != nil {
 		fmt.Printf("Error in saving refreshtoken in db: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Error generating refresh token")
+		return
 	}
+
+	token, err := cfg.makeAccessToken(user.ID, session.ID, user.Role, expiresIn*time.Second)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Error generating jwt token"))
+		return
+	}
+
 	resp := respBody{
 		ID:           user.ID,
 		CreatedAt:    user.CreatedAt,
@@ -147,6 +193,8 @@ func (cfg *ApiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 		Token:        token,
 		RefreshToken: refresh_token,
 		IsChirpyRed:  user.IsChirpyRed,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Role:         user.Role,
 	}
 
 	successData, err := json.Marshal(resp)
@@ -191,10 +239,11 @@ func (cfg *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusUnauthorized, "invalid authorization header format")
 		return
 	}
-	access_token := headerParts[1]
-	userId, err := auth.ValidateJWT(access_token, cfg.Secret)
+	// changing the password or email hands over the account, so it needs a
+	// real login; personal access tokens and OAuth access tokens are refused
+	userId, sessionId, err := cfg.authenticatedSession(r)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		respondWithError(w, http.StatusUnauthorized, "invalid access token")
 		return
 	}
 
@@ -209,18 +258,66 @@ func (cfg *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "decoding failed")
 		return
 	}
+	email, err := normalizeEmail(params.Email)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	currentUser, err := cfg.DB.GetUserByID(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	// a new address only replaces the current one once it is confirmed
+	emailChanged := email != currentUser.Email
+	passwordChanged := auth.CheckPasswordHash(currentUser.HashedPassword, params.Password) != nil
+	if passwordChanged && !cfg.checkNewPassword(w, params.Password, email) {
+		return
+	}
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "password could not be hashed")
+		return
 	}
+
+	tx, err := cfg.Conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "user details not updated")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DB.WithTx(tx)
+
 	newUserDetails := database.UpdateUserDetailsParams{
 		ID:             userId,
-		Email:          params.Email,
+		Email:          currentUser.Email,
 		HashedPassword: hashedPassword,
 	}
-	user, err := cfg.DB.UpdateUserDetails(r.Context(), newUserDetails)
+	user, err := qtx.UpdateUserDetails(r.Context(), newUserDetails)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "user details not updated")
+		return
+	}
+	// a new password logs out every other device
+	if passwordChanged {
+		if err := revokeOtherSessions(r.Context(), qtx, userId, sessionId); err != nil {
+			log.Printf("Error revoking sessions: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "user details not updated")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "user details not updated")
+		return
+	}
+	pendingEmail := ""
+	if emailChanged {
+		if err := cfg.startEmailVerification(r.Context(), userId, email); err != nil {
+			log.Printf("Error starting email verification: %v", err)
+			respondWithError(w, http.StatusInternalServerError, "failed to send verification email")
+			return
+		}
+		pendingEmail = email
 	}
 	type SuccessResp struct {
 		UserId    uuid.UUID `json:"user_id"`
@@ -228,6 +325,8 @@ func (cfg *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		IsChirpyRed bool     `json:"is_chirpy_red"`
 		CreatedAt time.Time `json:"created_at"`
 		UpdatedAt time.Time `json:"updated_at"`
+		// PendingEmail is the new address awaiting confirmation
+		PendingEmail string `json:"pending_email,omitempty"`
 	}
 	respondWithJSON(w, http.StatusOK, SuccessResp{
 		UserId:    user.ID,
@@ -235,6 +334,7 @@ func (cfg *ApiConfig) UpdateUserHandler(w http.ResponseWriter, r *http.Request)
 		IsChirpyRed: user.IsChirpyRed,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+		PendingEmail: pendingEmail,
 	})
 }
 
@@ -251,26 +351,46 @@ func (cfg *ApiConfig) RefreshHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	refreshToken := headerParts[1]
-	fmt.Printf("Token in refreshHandler: %v \n", refreshToken)
-	//Validate refresh Token
-	userId, err := cfg.DB.GetUserFromRefreshToken(r.Context(), refreshToken)
-	if err != nil {
+	//Validate and rotate refresh Token
+	userId, sessionId, newRefreshToken, err := cfg.rotateRefreshToken(r.Context(), refreshToken, cfg.requestMeta(r))
+	if errors.Is(err, errRefreshTokenReused) {
+		respondWithError(w, http.StatusUnauthorized, "Refresh token already used, please log in again")
+		return
+	}
+	if errors.Is(err, errRefreshTokenInvalid) {
 		respondWithError(w, http.StatusUnauthorized, "Invalid refresh Token")
 		return
 	}
+	if err != nil {
+		fmt.Printf("Error rotating refresh token: %v\n", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't refresh token")
+		return
+	}
 
+	// the role is read afresh, so a change shows up at the next refresh
+	user, err := cfg.DB.GetUserByID(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create access token")
+		return
+	}
+	if suspended(user, time.Now()) {
+		respondSuspended(w, user)
+		return
+	}
 	//Generate new access token
-	accessToken, err := auth.MakeJWT(userId, cfg.Secret, time.Duration(3600))
+	accessToken, err := cfg.makeAccessToken(userId, sessionId, user.Role, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create access token")
 		return
 	}
 	type RefreshResponse struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	respondWithJSON(w, http.StatusOK, RefreshResponse{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
 	})
 }
 
@@ -291,8 +411,13 @@ func (cfg *ApiConfig) RevokeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	refreshToken := headerParts[1]
 
-	// Revoke token
-	err := cfg.DB.RevokeRefreshToken(r.Context(), refreshToken)
+	// Revoke token and end the session it belongs to
+	err := cfg.DB.RevokeRefreshToken(r.Context(), cfg.hashToken(refreshToken))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+	err = cfg.DB.RevokeSessionByTokenHash(r.Context(), cfg.hashToken(refreshToken))
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Failed to revoke token")
 		return
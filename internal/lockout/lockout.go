@@ -0,0 +1,199 @@
+// Package lockout slows down password guessing. Failed logins are counted
+// per account and per client IP; past a threshold each further failure
+// locks the key for twice as long as the one before.
+package lockout
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy decides how failures for one kind of key turn into lockouts
+type Policy struct {
+	// Threshold is how many failures are allowed before the first lockout
+	Threshold int
+	// BaseDelay is the first lockout; each failure after it doubles
+	BaseDelay time.Duration
+	// MaxDelay caps a single lockout
+	MaxDelay time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+var (
+	// DefaultAccountPolicy protects one account from a slow, targeted guess
+	DefaultAccountPolicy = Policy{Threshold: 5, BaseDelay: 30 * time.Second, MaxDelay: time.Hour, Window: 24 * time.Hour}
+	// DefaultIPPolicy is looser, since many users can share an address,
+	// but catches one client spraying guesses over many accounts
+	DefaultIPPolicy = Policy{Threshold: 20, BaseDelay: 30 * time.Second, MaxDelay: time.Hour, Window: time.Hour}
+)
+
+// Delay is how long a key stays locked after its nth failure
+func (p Policy) Delay(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// Store keeps failure counts and lockouts. It must be shared by every
+// instance of the server for limits to hold across them.
+type Store interface {
+	// Fail records a failure for key at now and returns how many there
+	// have been, counting from one again if the last was before
+	// windowStart
+	Fail(ctx context.Context, key string, now, windowStart time.Time) (int, error)
+	// Lock keeps key locked until at least until
+	Lock(ctx context.Context, key string, until time.Time) error
+	// LockedUntil is when key's lockout ends, the zero time if it has none
+	LockedUntil(ctx context.Context, key string) (time.Time, error)
+	// Reset forgets key's failures and lockout
+	Reset(ctx context.Context, key string) error
+}
+
+// AccountKey is the key failures against an account are counted under.
+// The email need not belong to an account, so guessing at unknown
+// addresses is slowed down the same way.
+func AccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey is the key failures from a client address are counted under
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Guard applies an account and an IP policy to login attempts
+type Guard struct {
+	Store   Store
+	Account Policy
+	IP      Policy
+	// Now is the clock, time.Now when nil
+	Now func() time.Time
+}
+
+func (g *Guard) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+	return time.Now()
+}
+
+// Check returns how long the caller must wait before trying to log in to
+// email from ip; zero means go ahead
+func (g *Guard) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := g.now()
+	var wait time.Duration
+	for _, key := range []string{AccountKey(email), IPKey(ip)} {
+		until, err := g.Store.LockedUntil(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed login to email from ip and returns the lockout
+// it caused, if any
+func (g *Guard) Fail(ctx context.Context, email, ip string) (time.Duration, error) {
+	now := g.now()
+	var wait time.Duration
+	for _, k := range []struct {
+		key    string
+		policy Policy
+	}{{AccountKey(email), g.Account}, {IPKey(ip), g.IP}} {
+		failures, err := g.Store.Fail(ctx, k.key, now, now.Add(-k.policy.Window))
+		if err != nil {
+			return 0, err
+		}
+		delay := k.policy.Delay(failures)
+		if delay == 0 {
+			continue
+		}
+		if err := g.Store.Lock(ctx, k.key, now.Add(delay)); err != nil {
+			return 0, err
+		}
+		if delay > wait {
+			wait = delay
+		}
+	}
+	return wait, nil
+}
+
+// Succeed clears the account's failures after a correct password. The
+// IP's are kept, so one valid login can't launder a spray of guesses.
+func (g *Guard) Succeed(ctx context.Context, email string) error {
+	return g.Store.Reset(ctx, AccountKey(email))
+}
+
+// MemoryStore is a Store for a single instance, and for tests
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+type memoryEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]*memoryEntry{}}
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, key string, now, windowStart time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+	if entry.lastFailure.Before(windowStart) {
+		entry.failures = 0
+	}
+	entry.failures++
+	entry.lastFailure = now
+	return entry.failures, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok && until.After(entry.lockedUntil) {
+		entry.lockedUntil = until
+	}
+	return nil
+}
+
+func (s *MemoryStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		return entry.lockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
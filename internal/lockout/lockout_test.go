@@ -0,0 +1,76 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	p := Policy{Threshold: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	assert.Equal(t, time.Duration(0), p.Delay(2))
+	assert.Equal(t, time.Second, p.Delay(3))
+	assert.Equal(t, 2*time.Second, p.Delay(4))
+	assert.Equal(t, 8*time.Second, p.Delay(6))
+	assert.Equal(t, 10*time.Second, p.Delay(7))
+	assert.Equal(t, 10*time.Second, p.Delay(1000))
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	g := &Guard{
+		Store:   NewMemoryStore(),
+		Account: Policy{Threshold: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		IP:      Policy{Threshold: 4, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		Now:     func() time.Time { return now },
+	}
+
+	wait, err := g.Fail(ctx, "walt@breakingbad.com", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	wait, err = g.Fail(ctx, "Walt@BreakingBad.com", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, wait)
+
+	// the account is locked from anywhere
+	wait, err = g.Check(ctx, "walt@breakingbad.com", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, wait)
+
+	// other accounts from the same address aren't, until the IP's own limit
+	wait, err = g.Check(ctx, "jesse@breakingbad.com", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	_, err = g.Fail(ctx, "jesse@breakingbad.com", "10.0.0.1")
+	require.NoError(t, err)
+	wait, err = g.Fail(ctx, "skyler@breakingbad.com", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, wait)
+
+	// the lockout runs out, and backs off further on the next failure
+	now = now.Add(2 * time.Minute)
+	wait, err = g.Check(ctx, "walt@breakingbad.com", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+	wait, err = g.Fail(ctx, "walt@breakingbad.com", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, wait)
+
+	// a correct password clears the account
+	require.NoError(t, g.Succeed(ctx, "walt@breakingbad.com"))
+	wait, err = g.Check(ctx, "walt@breakingbad.com", "10.0.0.2")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+
+	// failures older than the window are forgotten
+	_, err = g.Fail(ctx, "walt@breakingbad.com", "10.0.0.3")
+	require.NoError(t, err)
+	now = now.Add(2 * time.Hour)
+	wait, err = g.Fail(ctx, "walt@breakingbad.com", "10.0.0.3")
+	require.NoError(t, err)
+	assert.Zero(t, wait)
+}
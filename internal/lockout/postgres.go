@@ -0,0 +1,44 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/database"
+)
+
+// PostgresStore keeps failures in the login_failures table, so every
+// instance sharing the database sees the same counts
+type PostgresStore struct {
+	DB *database.Queries
+}
+
+func (s PostgresStore) Fail(ctx context.Context, key string, now, windowStart time.Time) (int, error) {
+	failures, err := s.DB.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		Key:         key,
+		Now:         now,
+		WindowStart: windowStart,
+	})
+	return int(failures), err
+}
+
+func (s PostgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return s.DB.LockLoginKey(ctx, database.LockLoginKeyParams{LockedUntil: until, Key: key})
+}
+
+func (s PostgresStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	until, err := s.DB.GetLoginLock(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return until.Time, nil
+}
+
+func (s PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.DB.ResetLoginFailures(ctx, key)
+}
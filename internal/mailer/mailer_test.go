@@ -0,0 +1,119 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpSink is a minimal SMTP server that accepts one message
+type smtpSink struct {
+	listener net.Listener
+	from     string
+	rcpt     string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	sink := &smtpSink{listener: listener, done: make(chan struct{})}
+	t.Cleanup(func() { listener.Close() })
+	go sink.serve()
+	return sink
+}
+
+func (s *smtpSink) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 sink ready")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 sink")
+		case "MAIL":
+			s.from = arg
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.rcpt = arg
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	sink := newSMTPSink(t)
+	host, port, err := net.SplitHostPort(sink.listener.Addr().String())
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	m := &SMTPMailer{Host: host, Port: portNum, From: "Chirpy <noreply@chirpy.test>"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = m.Send(ctx, Message{To: "walt@breakingbad.com", Subject: "Reset your password", Body: "line one\nline two"})
+	require.NoError(t, err)
+	<-sink.done
+
+	assert.Equal(t, "FROM:<noreply@chirpy.test>", sink.from)
+	assert.Equal(t, "TO:<walt@breakingbad.com>", sink.rcpt)
+	assert.Contains(t, sink.data, "Subject: Reset your password\n")
+	assert.Contains(t, sink.data, "line one\nline two\n")
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	_, err := Format("noreply@chirpy.test", Message{To: "a@b.com\r\nBcc: evil@c.com", Subject: "hi"}, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := &FileMailer{From: "noreply@chirpy.test", Dir: dir}
+	require.NoError(t, m.Send(context.Background(), Message{To: "a@b.com", Subject: "hi", Body: "hello"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(string(data)))).ReadMIMEHeader()
+	require.NoError(t, err)
+	assert.Equal(t, "a@b.com", msg.Get("To"))
+	assert.Equal(t, "hi", msg.Get("Subject"))
+}
@@ -0,0 +1,70 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// gifFrames walks the blocks of a GIF without decompressing anything and
+// returns its frame count and the pixels of all its frames added up.
+// gif.DecodeAll holds every frame in memory at once, so an animation of
+// many highly compressed frames is refused here, with ErrTooLarge, before
+// it is decoded.
+func gifFrames(data []byte) (frames, pixels int, err error) {
+	if len(data) < 13 {
+		return 0, 0, ErrInvalidImage
+	}
+	i := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		// global color table
+		i += 3 << (flags&0x07 + 1)
+	}
+	// skipSubBlocks moves i past a run of data sub-blocks and the empty
+	// block ending it
+	skipSubBlocks := func() bool {
+		for i < len(data) {
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: a label, then sub-blocks
+			i += 2
+			if !skipSubBlocks() {
+				return 0, 0, ErrInvalidImage
+			}
+		case 0x2C: // image descriptor, then an optional local color table and the image data
+			if i+10 > len(data) {
+				return 0, 0, ErrInvalidImage
+			}
+			width := int(binary.LittleEndian.Uint16(data[i+5:]))
+			height := int(binary.LittleEndian.Uint16(data[i+7:]))
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			// LZW minimum code size
+			i++
+			if !skipSubBlocks() {
+				return 0, 0, ErrInvalidImage
+			}
+			frames++
+			pixels += width * height
+			if frames > MaxGIFFrames || pixels > MaxGIFPixels {
+				return 0, 0, ErrTooLarge
+			}
+		case 0x3B: // trailer
+			return frames, pixels, nil
+		default:
+			return 0, 0, ErrInvalidImage
+		}
+	}
+	// gif.DecodeAll decides whether a missing trailer matters
+	return frames, pixels, nil
+}
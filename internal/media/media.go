@@ -0,0 +1,168 @@
+// Package media validates uploaded images, strips their metadata, makes
+// thumbnails and stores the results.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxUploadSize is the largest image accepted, in bytes
+	MaxUploadSize = 5 << 20
+	// MaxPixels guards against small files that decode to huge images
+	MaxPixels = 40_000_000
+	// MaxGIFFrames and MaxGIFPixels do the same for animations, whose
+	// frames are all decoded at once; MaxGIFPixels covers all the frames
+	MaxGIFFrames = 1000
+	MaxGIFPixels = 100_000_000
+	// ThumbnailSize is the longest side of a thumbnail, in pixels
+	ThumbnailSize = 320
+)
+
+const (
+	TypePNG  = "image/png"
+	TypeJPEG = "image/jpeg"
+	TypeGIF  = "image/gif"
+	TypeWebP = "image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("only PNG, JPEG, GIF and WebP images are supported")
+	ErrTooLarge        = errors.New("image is too large")
+	ErrInvalidImage    = errors.New("image could not be decoded")
+)
+
+// Image is an upload that is safe to store: its type comes from its
+// content rather than the client and its metadata has been removed
+type Image struct {
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+	// Thumbnail is nil for formats that can't be decoded here (WebP);
+	// clients should fall back to the full image
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// Extension returns the file extension for a content type
+func Extension(contentType string) string {
+	switch contentType {
+	case TypePNG:
+		return ".png"
+	case TypeJPEG:
+		return ".jpg"
+	case TypeGIF:
+		return ".gif"
+	case TypeWebP:
+		return ".webp"
+	}
+	return ""
+}
+
+// Process sniffs data, rejects anything that isn't a supported image within
+// the size limits and returns it re-encoded without metadata, with a thumbnail
+func Process(data []byte) (*Image, error) {
+	if len(data) > MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case TypePNG, TypeJPEG, TypeGIF:
+		return processDecodable(data, contentType)
+	case TypeWebP:
+		return processWebP(data)
+	}
+	return nil, ErrUnsupportedType
+}
+
+func processDecodable(data []byte, contentType string) (*Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	// re-encoding keeps only pixel data, which drops EXIF, XMP, text
+	// chunks and GIF comments
+	var out bytes.Buffer
+	var first image.Image
+	switch contentType {
+	case TypeGIF:
+		if _, _, err := gifFrames(data); err != nil {
+			return nil, err
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		first = g.Image[0]
+		err = gif.EncodeAll(&out, &gif.GIF{
+			Image:     g.Image,
+			Delay:     g.Delay,
+			LoopCount: g.LoopCount,
+			Disposal:  g.Disposal,
+			Config:    g.Config,
+		})
+		if err != nil {
+			return nil, err
+		}
+	case TypeJPEG:
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		// the orientation tag is about to be stripped, so bake it in
+		first = applyOrientation(img, jpegOrientation(data))
+		if err := jpeg.Encode(&out, first, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+	case TypePNG:
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		first = img
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+	}
+
+	thumb, thumbType, err := encodeThumbnail(first, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{
+		ContentType:   contentType,
+		Data:          out.Bytes(),
+		Width:         first.Bounds().Dx(),
+		Height:        first.Bounds().Dy(),
+		Thumbnail:     thumb,
+		ThumbnailType: thumbType,
+	}, nil
+}
+
+// encodeThumbnail scales img to fit ThumbnailSize. Photos stay JPEG;
+// everything else becomes PNG to keep transparency.
+func encodeThumbnail(img image.Image, contentType string) ([]byte, string, error) {
+	thumb := Thumbnail(img, ThumbnailSize)
+	var out bytes.Buffer
+	if contentType == TypeJPEG {
+		if err := jpeg.Encode(&out, thumb, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, "", err
+		}
+		return out.Bytes(), TypeJPEG, nil
+	}
+	if err := png.Encode(&out, thumb); err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), TypePNG, nil
+}
@@ -0,0 +1,202 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+// withExif inserts an APP1 segment holding a big-endian TIFF with only an
+// orientation tag right after the JPEG SOI marker
+func withExif(t *testing.T, data []byte, orientation uint16) []byte {
+	t.Helper()
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = append(tiff, 0x00, 0x01) // one entry
+	entry := make([]byte, 12)
+	binary.BigEndian.PutUint16(entry[0:], 0x0112)
+	binary.BigEndian.PutUint16(entry[2:], 3) // SHORT
+	binary.BigEndian.PutUint32(entry[4:], 1)
+	binary.BigEndian.PutUint16(entry[8:], orientation)
+	tiff = append(tiff, entry...)
+	tiff = append(tiff, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessJPEGStripsExifAndAppliesOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, testImage(40, 20), nil))
+	data := withExif(t, buf.Bytes(), 6)
+	assert.Equal(t, 6, jpegOrientation(data))
+
+	img, err := Process(data)
+	require.NoError(t, err)
+	assert.Equal(t, TypeJPEG, img.ContentType)
+	assert.False(t, bytes.Contains(img.Data, []byte("Exif")))
+	// rotated 90° clockwise
+	assert.Equal(t, 20, img.Width)
+	assert.Equal(t, 40, img.Height)
+	assert.Equal(t, TypeJPEG, img.ThumbnailType)
+}
+
+func TestProcessPNGMakesThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage(800, 400)))
+
+	img, err := Process(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, TypePNG, img.ContentType)
+	assert.Equal(t, 800, img.Width)
+
+	thumb, err := png.Decode(bytes.NewReader(img.Thumbnail))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, ThumbnailSize, ThumbnailSize/2), thumb.Bounds())
+}
+
+func TestProcessRejects(t *testing.T) {
+	_, err := Process([]byte("<html>definitely not an image</html>"))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+
+	_, err = Process(make([]byte, MaxUploadSize+1))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	// a PNG header claiming more pixels than allowed
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, testImage(1, 1)))
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	_, err = Process(data)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func testGIF(t *testing.T, frames, w, h int) []byte {
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, g))
+	return buf.Bytes()
+}
+
+func TestProcessGIF(t *testing.T) {
+	data := testGIF(t, 3, 40, 30)
+	frames, pixels, err := gifFrames(data)
+	require.NoError(t, err)
+	assert.Equal(t, 3, frames)
+	assert.Equal(t, 3*40*30, pixels)
+
+	img, err := Process(data)
+	require.NoError(t, err)
+	assert.Equal(t, TypeGIF, img.ContentType)
+	g, err := gif.DecodeAll(bytes.NewReader(img.Data))
+	require.NoError(t, err)
+	assert.Len(t, g.Image, 3)
+}
+
+func TestProcessGIFRejectsLongAnimations(t *testing.T) {
+	_, err := Process(testGIF(t, MaxGIFFrames+1, 1, 1))
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	// frames each within MaxPixels that add up to more than MaxGIFPixels;
+	// their image data is never looked at
+	size := func(w, h int) []byte {
+		return binary.LittleEndian.AppendUint16(binary.LittleEndian.AppendUint16(nil, uint16(w)), uint16(h))
+	}
+	data := append([]byte("GIF89a"), size(5000, 5000)...)
+	data = append(data, 0, 0, 0)
+	for i := 0; i < 5; i++ {
+		data = append(data, 0x2C, 0, 0, 0, 0)
+		data = append(data, size(5000, 5000)...)
+		data = append(data, 0, 2, 1, 0x44, 0)
+	}
+	data = append(data, 0x3B)
+	_, err = Process(data)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func webpChunk(fourCC string, payload []byte) []byte {
+	out := []byte(fourCC)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(payload)))
+	out = append(out, payload...)
+	if len(payload)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func TestProcessWebPStripsMetadata(t *testing.T) {
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpFlagEXIF | webpFlagXMP
+	vp8x[4] = 99 // width 100
+	vp8x[7] = 49 // height 50
+	vp8l := []byte{0x2f, 0, 0, 0, 0}
+
+	var body []byte
+	body = append(body, "WEBP"...)
+	body = append(body, webpChunk("VP8X", vp8x)...)
+	body = append(body, webpChunk("VP8L", vp8l)...)
+	body = append(body, webpChunk("EXIF", []byte("camera serial"))...)
+	body = append(body, webpChunk("XMP ", []byte("<x/>"))...)
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	data = append(data, body...)
+
+	img, err := Process(data)
+	require.NoError(t, err)
+	assert.Equal(t, TypeWebP, img.ContentType)
+	assert.Equal(t, 100, img.Width)
+	assert.Equal(t, 50, img.Height)
+	assert.Nil(t, img.Thumbnail)
+	assert.False(t, bytes.Contains(img.Data, []byte("EXIF")))
+	assert.False(t, bytes.Contains(img.Data, []byte("camera serial")))
+	assert.Equal(t, byte(0), img.Data[20]&(webpFlagEXIF|webpFlagXMP))
+	assert.Equal(t, uint32(len(img.Data)-8), binary.LittleEndian.Uint32(img.Data[4:]))
+}
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir, "/app/assets/media/")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "a.png", TypePNG, []byte("data")))
+	got, err := os.ReadFile(filepath.Join(dir, "a.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(got))
+	assert.Equal(t, "/app/assets/media/a.png", store.URL("a.png"))
+
+	assert.ErrorIs(t, store.Put(ctx, "../escape.png", TypePNG, nil), ErrInvalidKey)
+	assert.NoError(t, store.Delete(ctx, "a.png"))
+	assert.NoError(t, store.Delete(ctx, "a.png"))
+}
@@ -0,0 +1,92 @@
+package media
+
+import (
+	"encoding/binary"
+)
+
+// VP8X feature flags for metadata chunks
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// processWebP strips EXIF and XMP chunks from a WebP file. There is no WebP
+// decoder in the standard library, so the image data is passed through
+// untouched and no thumbnail is made.
+func processWebP(data []byte) (*Image, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalidImage
+	}
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd > len(data) {
+		return nil, ErrInvalidImage
+	}
+
+	out := append([]byte{}, data[:12]...)
+	width, height := 0, 0
+	for i := 12; i < riffEnd; {
+		if i+8 > riffEnd {
+			return nil, ErrInvalidImage
+		}
+		fourCC := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		if i+8+size > riffEnd {
+			return nil, ErrInvalidImage
+		}
+		// chunks are padded to an even size
+		end := min(i+8+size+size%2, riffEnd)
+		chunk := data[i:end]
+		payload := data[i+8 : i+8+size]
+		i = end
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			if len(payload) < 10 {
+				return nil, ErrInvalidImage
+			}
+			chunk = append([]byte{}, chunk...)
+			chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			width = 1 + int(uint24(payload[4:]))
+			height = 1 + int(uint24(payload[7:]))
+		case "VP8 ":
+			if width == 0 {
+				// frame tag, then start code 9d 01 2a, then 14-bit sizes
+				if len(payload) < 10 || payload[3] != 0x9d || payload[4] != 0x01 || payload[5] != 0x2a {
+					return nil, ErrInvalidImage
+				}
+				width = int(binary.LittleEndian.Uint16(payload[6:]) & 0x3fff)
+				height = int(binary.LittleEndian.Uint16(payload[8:]) & 0x3fff)
+			}
+		case "VP8L":
+			if width == 0 {
+				if len(payload) < 5 || payload[0] != 0x2f {
+					return nil, ErrInvalidImage
+				}
+				bits := binary.LittleEndian.Uint32(payload[1:])
+				width = 1 + int(bits&0x3fff)
+				height = 1 + int(bits>>14&0x3fff)
+			}
+		}
+		out = append(out, chunk...)
+	}
+	if width == 0 || height == 0 {
+		return nil, ErrInvalidImage
+	}
+	if width*height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+
+	return &Image{
+		ContentType: TypeWebP,
+		Data:        out,
+		Width:       width,
+		Height:      height,
+	}, nil
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
@@ -0,0 +1,392 @@
+// Package moderation checks chirp bodies against a chain of filters.
+// Each rule says what happens to content it matches: it is masked, the
+// chirp is rejected, or the chirp is flagged for a moderator to review.
+package moderation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Action string
+
+const (
+	ActionMask   Action = "mask"
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+const (
+	KindWord  = "word"
+	KindRegex = "regex"
+)
+
+// Mask replaces masked content
+const Mask = "****"
+
+// DefaultWords are masked when no other rules are configured
+var DefaultWords = []string{"kerfuffle", "sharbert", "fornax"}
+
+var (
+	ErrTooLong  = errors.New("chirp is too long")
+	ErrRejected = errors.New("chirp contains content that is not allowed")
+)
+
+// Rule is one moderation rule. ID is empty for rules that don't come from
+// the database, such as word list files.
+type Rule struct {
+	ID      string
+	Kind    string
+	Pattern string
+	Action  Action
+}
+
+// Match records a rule that matched and the text it matched
+type Match struct {
+	Rule Rule
+	Text string
+}
+
+// Filter inspects body and returns it with any masking applied, along with
+// everything it matched
+type Filter interface {
+	Filter(body string) (string, []Match)
+}
+
+// Chain runs filters in order; each sees the output of the previous one
+type Chain []Filter
+
+func (c Chain) Filter(body string) (string, []Match) {
+	var matches []Match
+	for _, f := range c {
+		var m []Match
+		body, m = f.Filter(body)
+		matches = append(matches, m...)
+	}
+	return body, matches
+}
+
+// NewChain builds a filter chain from rules. All word rules share a single
+// WordFilter, which runs first; regex rules follow in the given order.
+func NewChain(rules []Rule) (Chain, error) {
+	words := NewWordFilter()
+	var chain Chain
+	for _, rule := range rules {
+		if err := ValidateRule(rule); err != nil {
+			return nil, err
+		}
+		switch rule.Kind {
+		case KindWord:
+			words.Add(rule)
+		case KindRegex:
+			chain = append(chain, &RegexFilter{
+				rule: rule,
+				re:   regexp.MustCompile(rule.Pattern),
+			})
+		}
+	}
+	return append(Chain{words}, chain...), nil
+}
+
+// ValidateRule reports whether rule could be used in a chain
+func ValidateRule(rule Rule) error {
+	switch rule.Action {
+	case ActionMask, ActionReject, ActionFlag:
+	default:
+		return fmt.Errorf("unknown action %q", rule.Action)
+	}
+	switch rule.Kind {
+	case KindWord:
+		if normalizeWord(rule.Pattern, false) == "" {
+			return errors.New("word rule has no letters or digits")
+		}
+	case KindRegex:
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown rule kind %q", rule.Kind)
+	}
+	return nil
+}
+
+// Result is the outcome of moderating a chirp
+type Result struct {
+	Body    string
+	Matches []Match
+}
+
+// Flagged reports whether any flag rule matched
+func (r Result) Flagged() bool {
+	for _, m := range r.Matches {
+		if m.Rule.Action == ActionFlag {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Result) rejected() bool {
+	for _, m := range r.Matches {
+		if m.Rule.Action == ActionReject {
+			return true
+		}
+	}
+	return false
+}
+
+// Moderator applies the current chain to chirps. Its rules can be swapped
+// at runtime and it is safe for concurrent use.
+type Moderator struct {
+	maxRunes int
+
+	mu    sync.RWMutex
+	chain Chain
+}
+
+// New returns a Moderator allowing chirps of up to maxRunes characters
+func New(maxRunes int, rules []Rule) (*Moderator, error) {
+	m := &Moderator{maxRunes: maxRunes}
+	if err := m.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Default returns a Moderator with the 140 character limit that masks DefaultWords
+func Default() *Moderator {
+	m, _ := New(140, WordRules(DefaultWords, ActionMask))
+	return m
+}
+
+// SetRules replaces every rule; on error the old rules stay in place
+func (m *Moderator) SetRules(rules []Rule) error {
+	chain, err := NewChain(rules)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.chain = chain
+	m.mu.Unlock()
+	return nil
+}
+
+// Moderate checks body. It returns ErrTooLong or ErrRejected when the chirp
+// may not be posted; otherwise Result.Body is the text to store.
+func (m *Moderator) Moderate(body string) (Result, error) {
+	if utf8.RuneCountInString(body) > m.maxRunes {
+		return Result{}, ErrTooLong
+	}
+	m.mu.RLock()
+	chain := m.chain
+	m.mu.RUnlock()
+
+	filtered, matches := chain.Filter(body)
+	result := Result{Body: filtered, Matches: matches}
+	if result.rejected() {
+		return result, ErrRejected
+	}
+	return result, nil
+}
+
+// WordRules turns a word list into rules that all take action
+func WordRules(words []string, action Action) []Rule {
+	rules := make([]Rule, 0, len(words))
+	for _, word := range words {
+		rules = append(rules, Rule{Kind: KindWord, Pattern: word, Action: action})
+	}
+	return rules
+}
+
+// LoadWordList reads one word per line. Blank lines and lines starting
+// with # are skipped.
+func LoadWordList(r io.Reader, action Action) ([]Rule, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return WordRules(words, action), nil
+}
+
+// LoadWordListFile is LoadWordList for a file on disk
+func LoadWordListFile(path string, action Action) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadWordList(f, action)
+}
+
+// RegexFilter applies one regex rule to the whole body
+type RegexFilter struct {
+	rule Rule
+	re   *regexp.Regexp
+}
+
+func (f *RegexFilter) Filter(body string) (string, []Match) {
+	found := f.re.FindAllString(body, -1)
+	if len(found) == 0 {
+		return body, nil
+	}
+	matches := make([]Match, 0, len(found))
+	for _, text := range found {
+		matches = append(matches, Match{Rule: f.rule, Text: text})
+	}
+	if f.rule.Action == ActionMask {
+		body = f.re.ReplaceAllLiteralString(body, Mask)
+	}
+	return body, matches
+}
+
+// WordFilter matches whole words after normalizing away case, accents,
+// full-width forms, invisible characters, punctuation inside words
+// ("f.o.r.n.a.x") and leet-speak ("f0rn@x").
+type WordFilter struct {
+	words map[string]Rule
+}
+
+func NewWordFilter() *WordFilter {
+	return &WordFilter{words: map[string]Rule{}}
+}
+
+// Add registers a word rule; a later rule for the same word replaces it
+func (f *WordFilter) Add(rule Rule) {
+	for _, leet := range []bool{false, true} {
+		if word := normalizeWord(rule.Pattern, leet); word != "" {
+			f.words[word] = rule
+		}
+	}
+}
+
+func (f *WordFilter) Filter(body string) (string, []Match) {
+	if len(f.words) == 0 {
+		return body, nil
+	}
+	var out strings.Builder
+	var matches []Match
+	last := 0
+	for _, span := range wordSpans(body) {
+		core := body[span[0]:span[1]]
+		rule, ok := f.words[normalizeWord(core, false)]
+		if !ok {
+			rule, ok = f.words[normalizeWord(core, true)]
+		}
+		if !ok {
+			continue
+		}
+		matches = append(matches, Match{Rule: rule, Text: core})
+		if rule.Action == ActionMask {
+			out.WriteString(body[last:span[0]])
+			out.WriteString(Mask)
+			last = span[1]
+		}
+	}
+	if last == 0 {
+		return body, matches
+	}
+	out.WriteString(body[last:])
+	return out.String(), matches
+}
+
+// wordSpans returns the byte ranges of each whitespace separated word with
+// surrounding punctuation trimmed off, so "fornax!" yields "fornax"
+func wordSpans(body string) [][2]int {
+	isCore := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '@' || r == '$'
+	}
+	var spans [][2]int
+	start := -1
+	for i, r := range body + " " {
+		if !unicode.IsSpace(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		word := body[start:i]
+		lo := strings.IndexFunc(word, isCore)
+		hi := strings.LastIndexFunc(word, isCore)
+		if lo >= 0 {
+			_, size := utf8.DecodeRuneInString(word[hi:])
+			spans = append(spans, [2]int{start + lo, start + hi + size})
+		}
+		start = -1
+	}
+	return spans
+}
+
+var leetReplacer = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l',
+}
+
+// normalizeWord folds word to lower-case unaccented letters and digits,
+// dropping everything else. With leet set, look-alike digits and symbols
+// are read as the letters they stand in for.
+func normalizeWord(word string, leet bool) string {
+	var b strings.Builder
+	for _, r := range word {
+		// full-width ASCII variants
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if leet {
+			if l, ok := leetReplacer[r]; ok {
+				r = l
+			}
+		}
+		r = foldAccent(unicode.ToLower(r))
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var accentFolds = map[rune]rune{}
+
+func init() {
+	for base, accented := range map[rune]string{
+		'a': "àáâãäåāăą",
+		'c': "çćĉċč",
+		'e': "èéêëēĕėęě",
+		'i': "ìíîïĩīĭįı",
+		'n': "ñńņňŉ",
+		'o': "òóôõöøōŏő",
+		's': "śŝşšß",
+		'u': "ùúûüũūŭůűų",
+		'y': "ýÿŷ",
+		'z': "źżž",
+	} {
+		for _, r := range accented {
+			accentFolds[r] = base
+		}
+	}
+}
+
+func foldAccent(r rune) rune {
+	if base, ok := accentFolds[r]; ok {
+		return base
+	}
+	return r
+}
@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultMasksProfanity(t *testing.T) {
+	m := Default()
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"I had something interesting for breakfast", "I had something interesting for breakfast"},
+		{"I hear Mastodon is better than Chirpy. sharbert I need to migrate", "I hear Mastodon is better than Chirpy. **** I need to migrate"},
+		{"I really need a kerfuffle to go to bed sooner, Fornax !", "I really need a **** to go to bed sooner, **** !"},
+		{"what a fornax!", "what a ****!"},
+		{"f0rn@x and (Kerfuffle)", "**** and (****)"},
+		{"ｆｏｒｎａｘ", "****"},
+		{"fornáx", "****"},
+		{"for​nax", "****"},
+		{"f.o.r.n.a.x", "****"},
+		{"fornaxes", "fornaxes"},
+	}
+	for _, tt := range tests {
+		result, err := m.Moderate(tt.body)
+		assert.NoError(t, err, tt.body)
+		assert.Equal(t, tt.want, result.Body, tt.body)
+	}
+}
+
+func TestLengthCountsRunes(t *testing.T) {
+	m := Default()
+
+	_, err := m.Moderate(strings.Repeat("é", 140))
+	assert.NoError(t, err)
+
+	_, err = m.Moderate(strings.Repeat("a", 141))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestRuleActions(t *testing.T) {
+	m, err := New(140, []Rule{
+		{Kind: KindWord, Pattern: "spam", Action: ActionReject},
+		{Kind: KindWord, Pattern: "crypto", Action: ActionFlag},
+		{Kind: KindRegex, Pattern: `\b\d{3}-\d{4}\b`, Action: ActionMask},
+	})
+	assert.NoError(t, err)
+
+	_, err = m.Moderate("buy my SPAM")
+	assert.ErrorIs(t, err, ErrRejected)
+
+	result, err := m.Moderate("call 555-1234 about crypto")
+	assert.NoError(t, err)
+	assert.Equal(t, "call **** about crypto", result.Body)
+	assert.True(t, result.Flagged())
+	assert.Len(t, result.Matches, 2)
+}
+
+func TestSetRulesKeepsOldRulesOnError(t *testing.T) {
+	m := Default()
+
+	err := m.SetRules([]Rule{{Kind: KindRegex, Pattern: "(", Action: ActionMask}})
+	assert.Error(t, err)
+
+	result, err := m.Moderate("fornax")
+	assert.NoError(t, err)
+	assert.Equal(t, Mask, result.Body)
+}
+
+func TestValidateRule(t *testing.T) {
+	assert.NoError(t, ValidateRule(Rule{Kind: KindWord, Pattern: "word", Action: ActionFlag}))
+	assert.Error(t, ValidateRule(Rule{Kind: KindWord, Pattern: "!!", Action: ActionFlag}))
+	assert.Error(t, ValidateRule(Rule{Kind: KindWord, Pattern: "word", Action: "delete"}))
+	assert.Error(t, ValidateRule(Rule{Kind: "glob", Pattern: "word", Action: ActionMask}))
+}
+
+func TestLoadWordList(t *testing.T) {
+	rules, err := LoadWordList(strings.NewReader("# comment\nfoo\n\n  bar  \n"), ActionReject)
+	assert.NoError(t, err)
+	assert.Equal(t, []Rule{
+		{Kind: KindWord, Pattern: "foo", Action: ActionReject},
+		{Kind: KindWord, Pattern: "bar", Action: ActionReject},
+	}, rules)
+}
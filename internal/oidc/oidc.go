@@ -0,0 +1,260 @@
+// Package oidc signs users in with an external OpenID Connect provider
+// using the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maxResponseBytes bounds what is read from a provider
+const maxResponseBytes = 1 << 20
+
+var ErrNonceMismatch = errors.New("id token nonce does not match")
+
+// Config is one provider users can log in with
+type Config struct {
+	// Name identifies the provider in URLs, such as "google"
+	Name string
+	// Issuer is the provider's issuer URL, where discovery starts
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the user back with a code
+	RedirectURL string
+	// Scopes requested besides openid; email is needed to link accounts
+	Scopes []string
+}
+
+// Discovery holds the parts of a provider's discovery document used here
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a verified ID token
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider talks to one OpenID Connect provider. Its discovery document
+// and keys are fetched on first use and cached; keys are fetched again
+// when a token names one that isn't known, which is how providers rotate.
+type Provider struct {
+	Config
+	Client *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// NewProvider returns a Provider for cfg using client, or
+// http.DefaultClient when client is nil
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Provider{Config: cfg, Client: client}
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(v)
+}
+
+// Discover returns the provider's discovery document. Its issuer must be
+// exactly the configured one, or tokens from another issuer could pass.
+func (p *Provider) Discover(ctx context.Context) (Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return *p.discovery, nil
+	}
+	var d Discovery
+	err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &d)
+	if err != nil {
+		return Discovery{}, fmt.Errorf("oidc %s: discovery: %w", p.Name, err)
+	}
+	if d.Issuer != p.Issuer {
+		return Discovery{}, fmt.Errorf("oidc %s: discovery issuer %q does not match %q", p.Name, d.Issuer, p.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return Discovery{}, fmt.Errorf("oidc %s: discovery document is missing endpoints", p.Name)
+	}
+	p.discovery = &d
+	return d, nil
+}
+
+// PKCEChallenge returns the S256 code challenge for verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL returns where to send the user to log in. state ties the
+// callback to this attempt, nonce ties the ID token to it, and verifier is
+// the PKCE code verifier kept for Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("oidc %s: authorization endpoint: %w", p.Name, err)
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, p.Scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", PKCEChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Exchange trades an authorization code for the provider's tokens and
+// returns the verified claims of the ID token
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret == "" {
+		form.Set("client_id", p.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc %s: token request: %w", p.Name, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&body); err != nil {
+		return Claims{}, fmt.Errorf("oidc %s: token response: %w", p.Name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("oidc %s: token request failed: %s %s", p.Name, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return Claims{}, fmt.Errorf("oidc %s: token response has no id_token", p.Name)
+	}
+	return p.VerifyIDToken(ctx, body.IDToken, nonce)
+}
+
+// idTokenClaims is an ID token's payload. email_verified is a string in
+// some providers' tokens, so it is decoded by hand.
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string          `json:"nonce"`
+	AuthorizedParty string          `json:"azp"`
+	Email           string          `json:"email"`
+	EmailVerified   json.RawMessage `json:"email_verified"`
+	Name            string          `json:"name"`
+}
+
+func (c idTokenClaims) emailVerified() bool {
+	var verified bool
+	if json.Unmarshal(c.EmailVerified, &verified) == nil {
+		return verified
+	}
+	var s string
+	return json.Unmarshal(c.EmailVerified, &s) == nil && s == "true"
+}
+
+// VerifyIDToken checks an ID token's signature against the provider's
+// keys, its issuer, that it was issued to this client, that it hasn't
+// expired and that it carries nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (Claims, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+	claims := &idTokenClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, d.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc %s: %w", p.Name, err)
+	}
+	// with several audiences the token must say it was meant for us
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.ClientID {
+		return Claims{}, fmt.Errorf("oidc %s: id token azp %q is not this client", p.Name, claims.AuthorizedParty)
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return Claims{}, ErrNonceMismatch
+	}
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("oidc %s: id token has no subject", p.Name)
+	}
+	return Claims{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.emailVerified(),
+		Name:          claims.Name,
+	}, nil
+}
@@ -0,0 +1,148 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Glenn444/chirpy/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func newTestProvider(t *testing.T, secret string) (*oidctest.IdP, *Provider) {
+	idp := oidctest.New("chirpy", secret)
+	t.Cleanup(idp.Close)
+	p := NewProvider(Config{
+		Name:         "mock",
+		Issuer:       idp.Issuer(),
+		ClientID:     "chirpy",
+		ClientSecret: secret,
+		RedirectURL:  "https://chirpy.example/app/login/oidc/callback",
+		Scopes:       []string{"email", "profile"},
+	}, nil)
+	return idp, p
+}
+
+// authorize follows AuthCodeURL to the provider and returns the code and
+// state it redirects back with
+func authorize(t *testing.T, p *Provider, state, nonce string) (string, string) {
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	require.NoError(t, err)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	for _, secret := range []string{"s3cret", ""} {
+		idp, p := newTestProvider(t, secret)
+		idp.SetUser(oidctest.User{Subject: "user-1", Email: "walt@example.com", EmailVerified: true, Name: "Walt"})
+
+		code, state := authorize(t, p, "state-1", "nonce-1")
+		assert.Equal(t, "state-1", state)
+		claims, err := p.Exchange(context.Background(), code, verifier, "nonce-1")
+		require.NoError(t, err)
+		assert.Equal(t, Claims{
+			Issuer:        idp.Issuer(),
+			Subject:       "user-1",
+			Email:         "walt@example.com",
+			EmailVerified: true,
+			Name:          "Walt",
+		}, claims)
+
+		// codes are single use
+		_, err = p.Exchange(context.Background(), code, verifier, "nonce-1")
+		assert.Error(t, err)
+	}
+}
+
+func TestExchangeChecksVerifierAndNonce(t *testing.T) {
+	idp, p := newTestProvider(t, "s3cret")
+	idp.SetUser(oidctest.User{Subject: "user-1"})
+
+	code, _ := authorize(t, p, "state", "nonce")
+	_, err := p.Exchange(context.Background(), code, verifier+"x", "nonce")
+	assert.Error(t, err)
+
+	code, _ = authorize(t, p, "state", "nonce")
+	_, err = p.Exchange(context.Background(), code, verifier, "other-nonce")
+	assert.ErrorIs(t, err, ErrNonceMismatch)
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	idp, p := newTestProvider(t, "s3cret")
+	user := oidctest.User{Subject: "user-1", Email: "walt@example.com", EmailVerified: true}
+	ctx := context.Background()
+
+	_, err := p.VerifyIDToken(ctx, idp.Sign(idp.Claims(user, "n")), "n")
+	require.NoError(t, err)
+
+	tests := map[string]func(jwt.MapClaims){
+		"wrong audience": func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"wrong issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example" },
+		"expired":        func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no expiry":      func(c jwt.MapClaims) { delete(c, "exp") },
+		"other azp":      func(c jwt.MapClaims) { c["aud"] = []string{"chirpy", "other"}; c["azp"] = "other" },
+		"no subject":     func(c jwt.MapClaims) { c["sub"] = "" },
+	}
+	for name, mutate := range tests {
+		claims := idp.Claims(user, "n")
+		mutate(claims)
+		_, err := p.VerifyIDToken(ctx, idp.Sign(claims), "n")
+		assert.Error(t, err, name)
+	}
+
+	// a token signed by a key the provider never published
+	other := oidctest.New("chirpy", "s3cret")
+	defer other.Close()
+	claims := idp.Claims(user, "n")
+	_, err = p.VerifyIDToken(ctx, other.Sign(claims), "n")
+	assert.Error(t, err)
+}
+
+func TestVerifyIDTokenAfterKeyRotation(t *testing.T) {
+	idp, p := newTestProvider(t, "s3cret")
+	user := oidctest.User{Subject: "user-1"}
+	ctx := context.Background()
+
+	_, err := p.VerifyIDToken(ctx, idp.Sign(idp.Claims(user, "n")), "n")
+	require.NoError(t, err)
+
+	// the new key is fetched once the old fetch is old enough
+	idp.RotateKey()
+	_, err = p.VerifyIDToken(ctx, idp.Sign(idp.Claims(user, "n")), "n")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	p.fetchedAt = time.Now().Add(-2 * minKeyRefetch)
+	_, err = p.VerifyIDToken(ctx, idp.Sign(idp.Claims(user, "n")), "n")
+	assert.NoError(t, err)
+}
+
+func TestEmailVerifiedAsString(t *testing.T) {
+	idp, p := newTestProvider(t, "s3cret")
+	claims := idp.Claims(oidctest.User{Subject: "user-1", Email: "walt@example.com"}, "n")
+	claims["email_verified"] = "true"
+	got, err := p.VerifyIDToken(context.Background(), idp.Sign(claims), "n")
+	require.NoError(t, err)
+	assert.True(t, got.EmailVerified)
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	idp := oidctest.New("chirpy", "")
+	defer idp.Close()
+	p := NewProvider(Config{Name: "mock", Issuer: idp.Issuer() + "/", ClientID: "chirpy"}, nil)
+	_, err := p.Discover(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefixLength is how many hex characters of a SHA-1 pick a range, as in
+// the Have I Been Pwned range API
+const prefixLength = 5
+
+// RangeSource returns the breached hash suffixes sharing a SHA-1 prefix.
+// Only the prefix of a password's hash ever leaves the checker, so a
+// source backed by a remote service never learns the password.
+type RangeSource interface {
+	Range(prefix string) (map[string]bool, error)
+}
+
+// BreachChecker looks passwords up in a RangeSource
+type BreachChecker struct {
+	Source RangeSource
+}
+
+// Breached reports whether password's SHA-1 is in the corpus
+func (c *BreachChecker) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := c.Source.Range(hash[:prefixLength])
+	if err != nil {
+		return false, err
+	}
+	return suffixes[hash[prefixLength:]], nil
+}
+
+// parseRange reads "SUFFIX:COUNT" lines, the range API's format. Suffixes
+// with a count of zero are padding and skipped.
+func parseRange(r io.Reader) (map[string]bool, error) {
+	suffixes := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		suffix, count, _ := strings.Cut(line, ":")
+		if strings.TrimSpace(count) == "0" {
+			continue
+		}
+		suffixes[strings.ToUpper(suffix)] = true
+	}
+	return suffixes, scanner.Err()
+}
+
+// DirSource reads ranges from a directory holding one file per prefix,
+// named like 5BAA6, as downloaded from the range API
+type DirSource struct {
+	Dir string
+}
+
+func (s DirSource) Range(prefix string) (map[string]bool, error) {
+	f, err := os.Open(filepath.Join(s.Dir, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseRange(f)
+}
+
+// MemorySource holds a whole corpus, grouped by prefix
+type MemorySource map[string]map[string]bool
+
+func (s MemorySource) Range(prefix string) (map[string]bool, error) {
+	return s[prefix], nil
+}
+
+// LoadHashList reads full SHA-1 hashes, one per line and optionally
+// followed by ":COUNT", into a MemorySource
+func LoadHashList(r io.Reader) (MemorySource, error) {
+	source := MemorySource{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("not a SHA-1 hash: %q", hash)
+		}
+		prefix := hash[:prefixLength]
+		if source[prefix] == nil {
+			source[prefix] = map[string]bool{}
+		}
+		source[prefix][hash[prefixLength:]] = true
+	}
+	return source, scanner.Err()
+}
+
+// LoadBreachCorpus opens path as a DirSource if it is a directory and
+// loads it with LoadHashList otherwise
+func LoadBreachCorpus(path string) (*BreachChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &BreachChecker{Source: DirSource{Dir: path}}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	source, err := LoadHashList(f)
+	if err != nil {
+		return nil, err
+	}
+	return &BreachChecker{Source: source}, nil
+}
@@ -0,0 +1,136 @@
+// Package password decides which new passwords are acceptable: long
+// enough, not built from the account's email, not on a list of common
+// passwords and not known from a breach.
+package password
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation codes
+const (
+	CodeTooShort      = "too_short"
+	CodeTooLong       = "too_long"
+	CodeContainsEmail = "contains_email"
+	CodeCommon        = "common"
+	CodeBreached      = "breached"
+)
+
+// Violation is one reason a password was refused, safe to show the user
+type Violation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// maxBcryptBytes is as much of a password as bcrypt looks at
+const maxBcryptBytes = 72
+
+// DefaultCommon is used when no common password list is configured
+var DefaultCommon = []string{
+	"123456", "123456789", "12345678", "password", "qwerty123", "qwerty1",
+	"111111", "12345", "secret", "123123", "1234567890", "1234567",
+	"000000", "qwerty", "abc123", "password1", "iloveyou", "11111111",
+	"dragon", "monkey", "letmein", "welcome", "football", "baseball",
+	"sunshine", "princess", "admin", "passw0rd", "trustno1", "chirpy",
+}
+
+// Policy is what a new password has to satisfy
+type Policy struct {
+	// MinLength is counted in characters
+	MinLength int
+	// MaxLength is counted in bytes and can't exceed what bcrypt uses
+	MaxLength int
+	// Common holds lowercased passwords that are refused outright
+	Common map[string]bool
+	// Breached, if set, is checked for passwords seen in breaches
+	Breached *BreachChecker
+}
+
+// DefaultPolicy follows NIST SP 800-63B: at least 8 characters, no
+// composition rules, and no common passwords
+func DefaultPolicy() *Policy {
+	return &Policy{MinLength: 8, MaxLength: maxBcryptBytes, Common: NewWordSet(DefaultCommon)}
+}
+
+// NewWordSet lowercases words into a set for Policy.Common
+func NewWordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[strings.ToLower(w)] = true
+	}
+	return set
+}
+
+// LoadWordList reads a common password list, one per line
+func LoadWordList(r io.Reader) (map[string]bool, error) {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			words = append(words, word)
+		}
+	}
+	return NewWordSet(words), scanner.Err()
+}
+
+// LoadWordListFile is LoadWordList for a file
+func LoadWordListFile(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadWordList(f)
+}
+
+// Check returns every rule password breaks for the account with email. An
+// error means the breach corpus couldn't be read; the violations found
+// without it are still returned.
+func (p *Policy) Check(password, email string) ([]Violation, error) {
+	violations := []Violation{}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{CodeTooShort, "password must be at least " + strconv.Itoa(p.MinLength) + " characters"})
+	}
+	maxLength := p.MaxLength
+	if maxLength <= 0 || maxLength > maxBcryptBytes {
+		maxLength = maxBcryptBytes
+	}
+	if len(password) > maxLength {
+		violations = append(violations, Violation{CodeTooLong, "password must be at most " + strconv.Itoa(maxLength) + " bytes"})
+	}
+	if containsEmail(password, email) {
+		violations = append(violations, Violation{CodeContainsEmail, "password must not contain your email address"})
+	}
+	if p.Common[strings.ToLower(password)] {
+		violations = append(violations, Violation{CodeCommon, "password is too common"})
+	}
+	if p.Breached != nil && password != "" {
+		breached, err := p.Breached.Breached(password)
+		if err != nil {
+			return violations, err
+		}
+		if breached {
+			violations = append(violations, Violation{CodeBreached, "password has appeared in a data breach"})
+		}
+	}
+	return violations, nil
+}
+
+// containsEmail catches the address itself and its local part, as long as
+// that is long enough to mean something
+func containsEmail(password, email string) bool {
+	password, email = strings.ToLower(password), strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return false
+	}
+	if strings.Contains(password, email) {
+		return true
+	}
+	local, _, _ := strings.Cut(email, "@")
+	return len(local) >= 4 && strings.Contains(password, local)
+}
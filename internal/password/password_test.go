@@ -0,0 +1,88 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func codes(violations []Violation) []string {
+	out := []string{}
+	for _, v := range violations {
+		out = append(out, v.Code)
+	}
+	return out
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := DefaultPolicy()
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"", []string{CodeTooShort}},
+		{"short", []string{CodeTooShort}},
+		{"correct horse battery staple", []string{}},
+		{"Password", []string{CodeCommon}},
+		{"heisenberg-walt@breakingbad.com", []string{CodeContainsEmail}},
+		{"heisenberg1", []string{CodeContainsEmail}},
+		{strings.Repeat("x", 73), []string{CodeTooLong}},
+	}
+	for _, tt := range tests {
+		got, err := p.Check(tt.password, "heisenberg@breakingbad.com")
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, codes(got), tt.password)
+	}
+}
+
+func TestLoadWordList(t *testing.T) {
+	words, err := LoadWordList(strings.NewReader("Hunter2\n\n  swordfish \n"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"hunter2": true, "swordfish": true}, words)
+}
+
+// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+const passwordSHA1 = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+func TestBreachCheckerMemory(t *testing.T) {
+	source, err := LoadHashList(strings.NewReader(strings.ToLower(passwordSHA1) + ":3861493\n"))
+	require.NoError(t, err)
+	c := &BreachChecker{Source: source}
+
+	breached, err := c.Breached("password")
+	require.NoError(t, err)
+	assert.True(t, breached)
+	breached, err = c.Breached("correct horse battery staple")
+	require.NoError(t, err)
+	assert.False(t, breached)
+
+	_, err = LoadHashList(strings.NewReader("not-a-hash\n"))
+	assert.Error(t, err)
+}
+
+func TestBreachCheckerDir(t *testing.T) {
+	dir := t.TempDir()
+	rangeFile := passwordSHA1[5:] + ":3861493\n" + "0018A45C4D1DEF81644B54AB7F969B88D65:0\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, passwordSHA1[:5]), []byte(rangeFile), 0o644))
+
+	c, err := LoadBreachCorpus(dir)
+	require.NoError(t, err)
+	breached, err := c.Breached("password")
+	require.NoError(t, err)
+	assert.True(t, breached)
+	// no file for the prefix means nothing is known
+	breached, err = c.Breached("correct horse battery staple")
+	require.NoError(t, err)
+	assert.False(t, breached)
+
+	p := DefaultPolicy()
+	p.Common = nil
+	p.Breached = c
+	violations, err := p.Check("password", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{CodeBreached}, codes(violations))
+}
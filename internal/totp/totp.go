@@ -0,0 +1,117 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as
+// used by authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is how long each code is valid for
+	Period = 30 * time.Second
+	// Digits is the length of a code
+	Digits = 6
+	// Skew is how many periods either side of now are accepted, to allow
+	// for clock drift and slow typing
+	Skew = 1
+	// secretSize is the size of generated secrets; 160 bits as RFC 4226 recommends
+	secretSize = 20
+)
+
+var ErrInvalidCode = errors.New("invalid code")
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Algorithm is the HMAC hash a code is computed with. Authenticator apps
+// only reliably support SHA1, so that is what this package issues.
+type Algorithm func() hash.Hash
+
+var (
+	SHA1   Algorithm = sha1.New
+	SHA256 Algorithm = sha256.New
+	SHA512 Algorithm = sha512.New
+)
+
+// GenerateSecret returns a new random secret, base32 encoded as
+// authenticator apps expect
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(secret), nil
+}
+
+// DecodeSecret parses a base32 secret, ignoring case, spaces and padding
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return b32.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// Step is the time step t falls in
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code computes the code for key at time step with the given hash and length
+func Code(key []byte, step int64, alg Algorithm, digits int) string {
+	mac := hmac.New(alg, key)
+	mac.Write(binary.BigEndian.AppendUint64(nil, uint64(step)))
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// Validate checks code against secret at time now, allowing Skew steps of
+// drift. It returns the step the code belongs to so callers can refuse to
+// accept the same step twice.
+func Validate(secret, code string, now time.Time) (int64, error) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, ErrInvalidCode
+	}
+	current := Step(now)
+	for step := current - Skew; step <= current+Skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(Code(key, step, SHA1, Digits)), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// URI is the otpauth:// URI authenticator apps import, usually shown as a
+// QR code
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period/time.Second)))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
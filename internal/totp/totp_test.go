@@ -0,0 +1,85 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Appendix B of RFC 6238
+func TestRFC6238Vectors(t *testing.T) {
+	keys := map[string][]byte{
+		"SHA1":   []byte("12345678901234567890"),
+		"SHA256": []byte("12345678901234567890123456789012"),
+		"SHA512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	algs := map[string]Algorithm{"SHA1": SHA1, "SHA256": SHA256, "SHA512": SHA512}
+
+	tests := []struct {
+		unix int64
+		alg  string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+		assert.Equal(t, tt.want, Code(keys[tt.alg], step, algs[tt.alg], 8), "%s at %d", tt.alg, tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	key, err := DecodeSecret(secret)
+	require.NoError(t, err)
+	assert.Len(t, key, secretSize)
+
+	now := time.Unix(1700000000, 0)
+	code := Code(key, Step(now), SHA1, Digits)
+
+	step, err := Validate(secret, code, now)
+	assert.NoError(t, err)
+	assert.Equal(t, Step(now), step)
+
+	// one period of drift either way is fine, two is not
+	_, err = Validate(secret, code, now.Add(Period))
+	assert.NoError(t, err)
+	_, err = Validate(secret, code, now.Add(-2*Period))
+	assert.ErrorIs(t, err, ErrInvalidCode)
+
+	_, err = Validate(secret, "12345", now)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Chirpy", "walt@breakingbad.com", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Chirpy:walt@breakingbad.com?"))
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	assert.Equal(t, "Chirpy", parsed.Query().Get("issuer"))
+	assert.Equal(t, "6", parsed.Query().Get("digits"))
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+    "github.com/Glenn444/chirpy/internal/auth"
     "github.com/Glenn444/chirpy/internal/database"
     "github.com/Glenn444/chirpy/internal/handler"
+    "github.com/Glenn444/chirpy/internal/lockout"
+    "github.com/Glenn444/chirpy/internal/mailer"
+    "github.com/Glenn444/chirpy/internal/media"
+    "github.com/Glenn444/chirpy/internal/moderation"
+    "github.com/Glenn444/chirpy/internal/oidc"
+    "github.com/Glenn444/chirpy/internal/password"
 )
 
 
@@ -39,42 +51,339 @@ func logRequest(handler http.Handler) http.Handler {
 }
 
 
-func main() {
-    godotenv.Load()
-    dbURL := os.Getenv("DB_URL")
-	Jwt_secret := os.Getenv("SECRET")
-	platform := os.Getenv("PLATFORM")
-	apiKey := os.Getenv("POLKA_KEY")
+// newMediaStore picks where uploaded images go. MEDIA_STORE=s3 uses an
+// S3-compatible bucket; otherwise they are written to assets/media and
+// served by the /app/ file server.
+func newMediaStore() (media.Store, error) {
+	if os.Getenv("MEDIA_STORE") == "s3" {
+		return &media.S3Store{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		}, nil
+	}
+	return media.NewLocalStore("assets/media", "/app/assets/media")
+}
 
-    db,err := sql.Open("postgres",dbURL)
-	
-    if err != nil{
-        log.Fatal("Error Occurred in db connection")
-    }
-    dbQueries := database.New(db)
-	cfg := &handler.ApiConfig{DB: dbQueries,Platform: platform,Secret:Jwt_secret,ApiKey:apiKey}
-	
+
+// appRoot is the directory the /app/ file server serves
+const appRoot = "."
+
+// insideDir reports whether dir is root or somewhere below it
+func insideDir(dir, root string) (bool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false, err
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// newMailer picks how account emails are delivered: MAIL_DRIVER=smtp sends
+// them through SMTP_HOST, MAIL_DRIVER=file saves them in MAIL_DIR (by
+// default chirpy-mail in the temp directory) and anything else just logs
+// them.
+func newMailer() (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <noreply@localhost>"
+	}
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			return nil, err
+		}
+		return &mailer.SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	case "file":
+		// the saved emails hold live reset and login tokens, so they must
+		// never land where the /app/ file server would hand them out
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "chirpy-mail")
+		}
+		inside, err := insideDir(dir, appRoot)
+		if err != nil {
+			return nil, err
+		}
+		if inside {
+			return nil, fmt.Errorf("MAIL_DIR %s is inside the served directory %s", dir, appRoot)
+		}
+		return &mailer.FileMailer{From: from, Dir: dir}, nil
+	}
+	return &mailer.LogMailer{From: from}, nil
+}
+
+
+// newOIDCProviders reads the OpenID Connect providers listed in
+// OIDC_PROVIDERS, such as "google,okta". Each is configured by
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET (empty for a public client)
+// and _SCOPES (default "email profile"). Providers send users back to
+// /app/login/oidc/callback, which must be registered with them.
+func newOIDCProviders(publicURL string) (map[string]*oidc.Provider, error) {
+	providers := map[string]*oidc.Provider{}
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == "callback" {
+			return nil, fmt.Errorf("oidc provider can't be named %q", name)
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		scopes := os.Getenv(prefix + "SCOPES")
+		if scopes == "" {
+			scopes = "email profile"
+		}
+		cfg := oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  publicURL + "/app/login/oidc/callback",
+			Scopes:       strings.Fields(scopes),
+		}
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("oidc provider %s needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		providers[name] = oidc.NewProvider(cfg, client)
+	}
+	return providers, nil
+}
+
+// newPasswordPolicy is the default policy with the common password list
+// replaced by PASSWORD_COMMON_LIST and breached passwords looked up in
+// BREACHED_PASSWORDS, a range directory or a file of SHA-1 hashes
+func newPasswordPolicy() (*password.Policy, error) {
+	policy := password.DefaultPolicy()
+	if path := os.Getenv("PASSWORD_COMMON_LIST"); path != "" {
+		common, err := password.LoadWordListFile(path)
+		if err != nil {
+			return nil, err
+		}
+		policy.Common = common
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS"); path != "" {
+		breached, err := password.LoadBreachCorpus(path)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = breached
+	}
+	return policy, nil
+}
+
+// newMux routes every endpoint to cfg's handlers
+func newMux(cfg *handler.ApiConfig) *http.ServeMux {
 	mux := http.NewServeMux()
 	//rh := http.RedirectHandler("tobitresearchconsulting.com",307)
-	fileServer := http.StripPrefix("/app/", http.FileServer(http.Dir(".")))
+	fileServer := http.StripPrefix("/app/", http.FileServer(http.Dir(appRoot)))
 	mux.Handle("/app/",cfg.MiddlewareMetricsInc(fileServer))
+	// pages the links in account emails open
+	mux.HandleFunc("GET /app/magic-link", cfg.MagicLinkPage)
+	mux.HandleFunc("GET /app/reset-password", cfg.ResetPasswordPage)
+	mux.HandleFunc("GET /app/verify-email", cfg.VerifyEmailPage)
+	mux.HandleFunc("GET /app/login/oidc/callback", cfg.OIDCCallbackPage)
 	mux.HandleFunc("GET /api/healthz",Health)
-	mux.HandleFunc("GET /admin/metrics",cfg.MetricsHandler)
-	mux.HandleFunc("POST /admin/reset",cfg.DeleteUsers)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.JWKS)
+
+	// everything under /admin/ needs at least the moderator role; the
+	// routes that manage the site itself need admin
+	adminMux := http.NewServeMux()
+	admin := func(h http.HandlerFunc) http.Handler { return cfg.RequireRole(auth.RoleAdmin, h) }
+	adminMux.Handle("GET /admin/metrics", admin(cfg.MetricsHandler))
+	adminMux.Handle("POST /admin/reset", admin(cfg.DeleteUsers))
+	adminMux.HandleFunc("GET /admin/moderation/rules", cfg.GetModerationRules)
+	adminMux.HandleFunc("POST /admin/moderation/rules", cfg.CreateModerationRule)
+	adminMux.HandleFunc("DELETE /admin/moderation/rules/{id}", cfg.DeleteModerationRule)
+	adminMux.HandleFunc("GET /admin/moderation/flags", cfg.GetModerationFlags)
+	adminMux.HandleFunc("POST /admin/moderation/flags/{id}/resolve", cfg.ResolveModerationFlag)
+	adminMux.Handle("POST /admin/login-lockouts/unlock", admin(cfg.UnlockLogin))
+	adminMux.Handle("GET /admin/users", admin(cfg.GetAdminUsers))
+	adminMux.Handle("GET /admin/users/{id}", admin(cfg.GetAdminUser))
+	adminMux.Handle("DELETE /admin/users/{id}", admin(cfg.DeleteAdminUser))
+	adminMux.Handle("PUT /admin/users/{id}/role", admin(cfg.UpdateUserRole))
+	adminMux.Handle("PUT /admin/users/{id}/suspension", admin(cfg.SuspendUser))
+	adminMux.Handle("DELETE /admin/users/{id}/suspension", admin(cfg.UnsuspendUser))
+	adminMux.Handle("POST /admin/users/{id}/logout", admin(cfg.LogoutUser))
+	mux.Handle("/admin/", cfg.RequireRole(auth.RoleModerator, adminMux))
 	// mux.HandleFunc("POST /api/validate_chirp",cfg.CreateChirps)
    
 	mux.HandleFunc("POST /api/chirps", cfg.CreateChirps)
 	mux.HandleFunc("GET /api/chirps", cfg.GetAllChirps)
+	mux.HandleFunc("GET /api/chirps/search", cfg.SearchChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}",cfg.GetAChirp)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.EditChirp)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.GetChirpRevisions)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.GetChirpThread)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", cfg.Rechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", cfg.Unrechirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", cfg.LikeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", cfg.UnlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/reactions/{reaction}", cfg.AddReaction)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/reactions/{reaction}", cfg.RemoveReaction)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.DeleteChirps);
+	mux.HandleFunc("POST /api/media", cfg.UploadMedia)
+	mux.HandleFunc("GET /api/hashtags/trending", cfg.GetTrendingHashtags)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.GetHashtagChirps)
 
 	mux.HandleFunc("POST /api/users", cfg.CreateUser)
 	mux.HandleFunc("POST /api/login", cfg.LoginUser);
+	mux.HandleFunc("POST /api/login/mfa", cfg.LoginMFA)
+	mux.HandleFunc("POST /api/login/magic-link", cfg.RequestMagicLink)
+	mux.HandleFunc("POST /api/login/magic-link/verify", cfg.LoginMagicLink)
+	mux.HandleFunc("GET /api/login/oidc", cfg.GetOIDCProviders)
+	mux.HandleFunc("POST /api/login/oidc/callback", cfg.FinishOIDCLogin)
+	mux.HandleFunc("POST /api/login/oidc/{provider}", cfg.StartOIDCLogin)
 	mux.HandleFunc("POST /api/refresh", cfg.RefreshHandler);
 	mux.HandleFunc("POST /api/revoke", cfg.RevokeHandler);
 	mux.HandleFunc("PUT /api/users", cfg.UpdateUserHandler);
+	mux.HandleFunc("POST /api/password/forgot", cfg.ForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", cfg.ResetPassword)
+	mux.HandleFunc("POST /api/users/verify-email", cfg.VerifyEmail)
+	mux.HandleFunc("POST /api/users/verify-email/resend", cfg.ResendEmailVerification)
+	mux.HandleFunc("GET /api/sessions", cfg.GetSessions)
+	mux.HandleFunc("DELETE /api/sessions", cfg.DeleteOtherSessions)
+	mux.HandleFunc("DELETE /api/sessions/{id}", cfg.DeleteSession)
+	mux.HandleFunc("POST /api/tokens", cfg.CreatePersonalAccessToken)
+	mux.HandleFunc("GET /api/tokens", cfg.GetPersonalAccessTokens)
+	mux.HandleFunc("DELETE /api/tokens/{id}", cfg.DeletePersonalAccessToken)
+	mux.HandleFunc("POST /api/oauth/clients", cfg.CreateOAuthClient)
+	mux.HandleFunc("GET /api/oauth/clients", cfg.GetOAuthClients)
+	mux.HandleFunc("DELETE /api/oauth/clients/{id}", cfg.DeleteOAuthClient)
+	mux.HandleFunc("GET /api/authorized-apps", cfg.GetAuthorizedApps)
+	mux.HandleFunc("DELETE /api/authorized-apps/{id}", cfg.DeleteAuthorizedApp)
+	mux.HandleFunc("GET /oauth/authorize", cfg.OAuthAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", cfg.OAuthAuthorizeDecision)
+	mux.HandleFunc("POST /oauth/token", cfg.OAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", cfg.OAuthRevoke)
+	mux.HandleFunc("POST /oauth/introspect", cfg.OAuthIntrospect)
+	mux.HandleFunc("GET /.well-known/oauth-authorization-server", cfg.OAuthMetadata)
+	mux.HandleFunc("POST /api/2fa/totp/enroll", cfg.EnrollTOTP)
+	mux.HandleFunc("POST /api/2fa/totp/confirm", cfg.ConfirmTOTP)
+	mux.HandleFunc("DELETE /api/2fa/totp", cfg.DisableTOTP)
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.UpgradeUserHandler);
+	mux.HandleFunc("POST /api/users/{id}/follow", cfg.FollowUser)
+	mux.HandleFunc("DELETE /api/users/{id}/follow", cfg.UnfollowUser)
+	mux.HandleFunc("GET /api/users/{id}/followers", cfg.GetFollowers)
+	mux.HandleFunc("GET /api/users/{id}/following", cfg.GetFollowing)
+	mux.HandleFunc("GET /api/timeline", cfg.GetHomeTimeline)
+	return mux
+}
+
+
+func main() {
+    godotenv.Load()
+    dbURL := os.Getenv("DB_URL")
+	Jwt_secret := os.Getenv("SECRET")
+	platform := os.Getenv("PLATFORM")
+	apiKey := os.Getenv("POLKA_KEY")
+
+    db,err := sql.Open("postgres",dbURL)
+	
+    if err != nil{
+        log.Fatal("Error Occurred in db connection")
+    }
+    dbQueries := database.New(db)
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), dbQueries, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	cfg := &handler.ApiConfig{DB: dbQueries,Conn: db,Platform: platform,Secret:Jwt_secret,ApiKey:apiKey}
+	cfg.RefreshTokenKey = os.Getenv("REFRESH_TOKEN_KEY")
+	cfg.TrustProxyHeaders = os.Getenv("TRUST_PROXY") == "true"
+	if cost := os.Getenv("BCRYPT_COST"); cost != "" {
+		auth.PasswordCost, err = strconv.Atoi(cost)
+		if err != nil {
+			log.Fatalf("Invalid BCRYPT_COST: %v", err)
+		}
+	}
+	cfg.PasswordPolicy, err = newPasswordPolicy()
+	if err != nil {
+		log.Fatalf("Error loading password policy: %v", err)
+	}
+	cfg.Lockout = &lockout.Guard{
+		Store:   lockout.PostgresStore{DB: dbQueries},
+		Account: lockout.DefaultAccountPolicy,
+		IP:      lockout.DefaultIPPolicy,
+	}
+	if keysDir := os.Getenv("JWT_KEYS_DIR"); keysDir != "" {
+		issuer := os.Getenv("JWT_ISSUER")
+		if issuer == "" {
+			issuer = "chirpy"
+		}
+		audience := os.Getenv("JWT_AUDIENCE")
+		if audience == "" {
+			audience = "chirpy-api"
+		}
+		cfg.Keys, err = auth.LoadKeySetDir(keysDir, os.Getenv("JWT_ACTIVE_KID"), issuer, audience)
+		if err != nil {
+			log.Fatalf("Error loading JWT keys: %v", err)
+		}
+	}
+	if upgraded, err := cfg.HashLegacyRefreshTokens(context.Background()); err != nil {
+		log.Printf("Error hashing stored refresh tokens: %v", err)
+	} else if upgraded > 0 {
+		log.Printf("Hashed %d stored refresh tokens", upgraded)
+	}
+	cfg.ModerationWordList = os.Getenv("MODERATION_WORDLIST")
+	cfg.Moderator = moderation.Default()
+	if err := cfg.ReloadModerationRules(context.Background()); err != nil {
+		log.Printf("Error loading moderation rules, using defaults: %v", err)
+	}
+	cfg.Media, err = newMediaStore()
+	if err != nil {
+		log.Fatalf("Error setting up media storage: %v", err)
+	}
+	cfg.Mailer, err = newMailer()
+	if err != nil {
+		log.Fatalf("Error setting up mail delivery: %v", err)
+	}
+	restrictions := os.Getenv("UNVERIFIED_RESTRICTIONS")
+	if restrictions == "" {
+		restrictions = "chirp,media"
+	}
+	cfg.UnverifiedRestrictions = map[string]bool{}
+	for _, action := range strings.Split(restrictions, ",") {
+		cfg.UnverifiedRestrictions[strings.TrimSpace(action)] = true
+	}
+	cfg.PublicURL = os.Getenv("PUBLIC_URL")
+	if cfg.PublicURL == "" {
+		cfg.PublicURL = "http://localhost:8080"
+	}
+	cfg.OIDCProviders, err = newOIDCProviders(cfg.PublicURL)
+	if err != nil {
+		log.Fatalf("Error setting up OIDC login: %v", err)
+	}
+	// pick up rules changed by other instances
+	go func() {
+		for range time.Tick(time.Minute) {
+			if err := cfg.ReloadModerationRules(context.Background()); err != nil {
+				log.Printf("Error reloading moderation rules: %v", err)
+			}
+		}
+	}()
 	
+	mux := newMux(cfg)
 
 	loggedMux := logRequest(mux)
 
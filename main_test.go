@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Glenn444/chirpy/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsideDir(t *testing.T) {
+	for dir, want := range map[string]bool{
+		".":                true,
+		"mail":             true,
+		"./assets/../mail": true,
+		"..":               false,
+		"../mail":          false,
+		"..mail":           true,
+		os.TempDir():       false,
+		filepath.Join(os.TempDir(), "chirpy-mail"): false,
+	} {
+		got, err := insideDir(dir, ".")
+		require.NoError(t, err)
+		assert.Equal(t, want, got, dir)
+	}
+}
+
+func TestNewMailerRefusesServedDir(t *testing.T) {
+	t.Setenv("MAIL_DRIVER", "file")
+	t.Setenv("MAIL_DIR", "mail")
+	_, err := newMailer()
+	assert.Error(t, err)
+
+	t.Setenv("MAIL_DIR", "")
+	_, err = newMailer()
+	assert.NoError(t, err)
+}
+
+func TestEmailLinkPagesAreServed(t *testing.T) {
+	mux := newMux(&handler.ApiConfig{})
+	for _, path := range []string{"/app/magic-link", "/app/reset-password", "/app/verify-email"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?token=abc", nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Contains(t, w.Body.String(), `name="token" value="abc"`, path)
+	}
+}
+
+func TestOIDCRedirectIsServed(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", "mock")
+	t.Setenv("OIDC_MOCK_ISSUER", "https://idp.example")
+	t.Setenv("OIDC_MOCK_CLIENT_ID", "chirpy")
+	providers, err := newOIDCProviders("https://chirpy.example")
+	require.NoError(t, err)
+	redirect, err := url.Parse(providers["mock"].RedirectURL)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	newMux(&handler.ApiConfig{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, redirect.Path+"?code=c0de&state=st4te", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `name="code" value="c0de"`)
+	assert.Contains(t, w.Body.String(), `name="state" value="st4te"`)
+}
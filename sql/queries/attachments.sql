@@ -0,0 +1,31 @@
+-- name: CreateAttachment :one
+INSERT INTO chirp_attachments(id, user_id, content_type, size_bytes, width, height, storage_key, thumbnail_key, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    NOW()
+)
+RETURNING *;
+
+-- name: AttachToChirp :execrows
+UPDATE chirp_attachments SET chirp_id = $1, position = $2
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL;
+
+-- name: GetAttachmentsForChirps :many
+SELECT * FROM chirp_attachments
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: DeleteChirpAttachments :many
+DELETE FROM chirp_attachments WHERE chirp_id = $1
+RETURNING storage_key, thumbnail_key;
+
+-- name: DeleteUserAttachments :many
+DELETE FROM chirp_attachments WHERE user_id = $1
+RETURNING storage_key, thumbnail_key;
@@ -0,0 +1,106 @@
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: SearchChirpsByRank :many
+WITH matches AS (
+    SELECT id, created_at, updated_at, user_id, body, parent_id, reply_count, kind, original_id, edited_at,
+        ts_rank(body_tsv, to_tsquery('english', sqlc.arg('query'))) AS rank
+    FROM chirps
+    WHERE body_tsv @@ to_tsquery('english', sqlc.arg('query'))
+AND deleted_at IS NULL
+)
+SELECT matches.id, matches.created_at, matches.updated_at, matches.user_id, matches.body,
+    matches.parent_id, matches.reply_count, matches.kind, matches.original_id, matches.edited_at, matches.rank,
+    -- the body is HTML-escaped first, so the only markup is the <mark> tags
+    ts_headline('english', replace(replace(replace(replace(matches.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), to_tsquery('english', sqlc.arg('query')),
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS snippet
+FROM matches
+WHERE (
+    sqlc.narg('cursor_rank')::real IS NULL
+    OR (matches.rank, matches.created_at, matches.id) < (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY matches.rank DESC, matches.created_at DESC, matches.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: SearchChirpsAsc :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, reply_count, kind, original_id, edited_at,
+    ts_rank(body_tsv, to_tsquery('english', sqlc.arg('query'))) AS rank,
+    ts_headline('english', replace(replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), to_tsquery('english', sqlc.arg('query')),
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS snippet
+FROM chirps
+WHERE body_tsv @@ to_tsquery('english', sqlc.arg('query'))
+AND deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: SearchChirpsDesc :many
+SELECT id, created_at, updated_at, user_id, body, parent_id, reply_count, kind, original_id, edited_at,
+    ts_rank(body_tsv, to_tsquery('english', sqlc.arg('query'))) AS rank,
+    ts_headline('english', replace(replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), to_tsquery('english', sqlc.arg('query')),
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')::text AS snippet
+FROM chirps
+WHERE body_tsv @@ to_tsquery('english', sqlc.arg('query'))
+AND deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetThreadChirps :many
+SELECT * FROM chirps
+WHERE id = sqlc.arg('root_id') OR root_id = sqlc.arg('root_id')
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: IncrementReplyCount :exec
+UPDATE chirps SET reply_count = reply_count + 1
+WHERE id = $1;
+
+-- name: DecrementReplyCount :exec
+UPDATE chirps SET reply_count = GREATEST(reply_count - 1, 0)
+WHERE id = $1;
+
+-- name: TombstoneChirp :exec
+UPDATE chirps SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps WHERE id = $1
+FOR UPDATE;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: DeleteRechirpsOf :exec
+DELETE FROM chirps
+WHERE original_id = $1 AND kind = 'rechirp';
+
+-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+WHERE user_id = sqlc.arg('user_id')::uuid AND original_id = sqlc.arg('original_id') AND kind = 'rechirp';
@@ -0,0 +1,17 @@
+-- name: CreateEmailVerification :exec
+INSERT INTO email_verifications(id, token_hash, user_id, email, expires_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW());
+
+-- name: UseEmailVerification :one
+UPDATE email_verifications SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: ExpireEmailVerifications :exec
+UPDATE email_verifications SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: SetVerifiedEmail :one
+UPDATE users SET email = $2, email_verified_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
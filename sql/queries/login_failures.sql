@@ -0,0 +1,18 @@
+-- name: RecordLoginFailure :one
+INSERT INTO login_failures(key, failures, last_failure_at)
+VALUES (sqlc.arg('key'), 1, sqlc.arg('now'))
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE WHEN login_failures.last_failure_at < sqlc.arg('window_start') THEN 1
+                    ELSE login_failures.failures + 1 END,
+    last_failure_at = EXCLUDED.last_failure_at
+RETURNING failures;
+
+-- name: LockLoginKey :exec
+UPDATE login_failures SET locked_until = GREATEST(locked_until, sqlc.arg('locked_until'))
+WHERE key = sqlc.arg('key');
+
+-- name: GetLoginLock :one
+SELECT locked_until FROM login_failures WHERE key = $1;
+
+-- name: ResetLoginFailures :exec
+DELETE FROM login_failures WHERE key = $1;
@@ -0,0 +1,20 @@
+-- name: CreateMagicLink :exec
+INSERT INTO magic_links(id, token_hash, user_id, expires_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW());
+
+-- name: CountRecentMagicLinks :one
+SELECT COUNT(*) FROM magic_links
+WHERE user_id = $1 AND created_at > $2;
+
+-- name: GetMagicLinkForUpdate :one
+SELECT * FROM magic_links
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: UseMagicLink :exec
+UPDATE magic_links SET used_at = NOW()
+WHERE id = $1;
+
+-- name: ExpireMagicLinks :exec
+UPDATE magic_links SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL;
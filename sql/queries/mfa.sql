@@ -0,0 +1,46 @@
+-- name: UpsertPendingTOTP :execrows
+INSERT INTO user_totp(user_id, secret, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW(), last_used_step = NULL
+WHERE user_totp.confirmed_at IS NULL;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp WHERE user_id = $1;
+
+-- name: ConfirmTOTP :execrows
+UPDATE user_totp SET confirmed_at = NOW()
+WHERE user_id = $1 AND confirmed_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp SET last_used_step = sqlc.arg('step')::bigint
+WHERE user_id = sqlc.arg('user_id') AND (last_used_step IS NULL OR last_used_step < sqlc.arg('step')::bigint);
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes(id, user_id, code_hash, created_at)
+VALUES (gen_random_uuid(), $1, $2, NOW());
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: CreateMFAChallenge :exec
+INSERT INTO mfa_challenges(id, token_hash, user_id, expires_in_seconds, expires_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW());
+
+-- name: AttemptMFAChallenge :one
+UPDATE mfa_challenges SET attempts = attempts + 1
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: UseMFAChallenge :execrows
+UPDATE mfa_challenges SET used_at = NOW()
+WHERE id = $1 AND used_at IS NULL;
@@ -0,0 +1,38 @@
+-- name: ListModerationRules :many
+SELECT * FROM moderation_rules
+ORDER BY created_at ASC, id ASC;
+
+-- name: CreateModerationRule :one
+INSERT INTO moderation_rules(id, kind, pattern, action, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+RETURNING *;
+
+-- name: DeleteModerationRule :execrows
+DELETE FROM moderation_rules WHERE id = $1;
+
+-- name: CreateModerationFlag :exec
+INSERT INTO moderation_flags(id, chirp_id, rule_kind, pattern, matched, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+);
+
+-- name: ListOpenModerationFlags :many
+SELECT * FROM moderation_flags
+WHERE resolved_at IS NULL
+ORDER BY created_at ASC, id ASC
+LIMIT $1;
+
+-- name: ResolveModerationFlag :execrows
+UPDATE moderation_flags SET resolved_at = NOW()
+WHERE id = $1 AND resolved_at IS NULL;
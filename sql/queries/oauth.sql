@@ -0,0 +1,89 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients(id, client_id, client_secret_hash, name, redirect_uris, owner_id, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW())
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE client_id = $1 AND revoked_at IS NULL;
+
+-- name: ListOAuthClients :many
+SELECT * FROM oauth_clients
+WHERE owner_id = $1 AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: RevokeOAuthClient :one
+UPDATE oauth_clients SET revoked_at = NOW()
+WHERE id = $1 AND owner_id = $2 AND revoked_at IS NULL
+RETURNING *;
+
+-- name: UpsertOAuthGrant :one
+-- a client asking again replaces the scopes the user consented to
+INSERT INTO oauth_grants(id, user_id, client_id, scopes, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW(), NOW())
+ON CONFLICT (user_id, client_id) WHERE revoked_at IS NULL
+DO UPDATE SET scopes = EXCLUDED.scopes, updated_at = NOW()
+RETURNING *;
+
+-- name: GetOAuthGrant :one
+SELECT * FROM oauth_grants
+WHERE id = $1;
+
+-- name: ListOAuthGrants :many
+SELECT oauth_grants.id, oauth_clients.client_id, oauth_clients.name, oauth_grants.scopes,
+       oauth_grants.created_at, oauth_grants.updated_at
+FROM oauth_grants
+JOIN oauth_clients ON oauth_clients.id = oauth_grants.client_id
+WHERE oauth_grants.user_id = $1 AND oauth_grants.revoked_at IS NULL AND oauth_clients.revoked_at IS NULL
+ORDER BY oauth_grants.updated_at DESC;
+
+-- name: RevokeOAuthGrant :execrows
+UPDATE oauth_grants SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrantByID :exec
+UPDATE oauth_grants SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrantsForClient :exec
+UPDATE oauth_grants SET revoked_at = NOW()
+WHERE client_id = $1 AND revoked_at IS NULL;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes(id, code_hash, grant_id, redirect_uri, scopes, code_challenge, expires_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, NOW());
+
+-- name: GetOAuthAuthorizationCodeForUpdate :one
+SELECT * FROM oauth_authorization_codes
+WHERE code_hash = $1
+FOR UPDATE;
+
+-- name: UseOAuthAuthorizationCode :exec
+UPDATE oauth_authorization_codes SET used_at = NOW()
+WHERE id = $1;
+
+-- name: CreateOAuthRefreshToken :exec
+INSERT INTO oauth_refresh_tokens(id, token_hash, grant_id, scopes, expires_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW());
+
+-- name: GetOAuthRefreshTokenForUpdate :one
+SELECT * FROM oauth_refresh_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: RevokeOAuthRefreshToken :exec
+UPDATE oauth_refresh_tokens SET revoked_at = NOW()
+WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthRefreshTokensForGrant :exec
+UPDATE oauth_refresh_tokens SET revoked_at = NOW()
+WHERE grant_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthGrantsForUser :exec
+UPDATE oauth_grants SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOAuthRefreshTokensForUser :exec
+UPDATE oauth_refresh_tokens SET revoked_at = NOW()
+WHERE grant_id IN (SELECT id FROM oauth_grants WHERE user_id = $1)
+AND revoked_at IS NULL;
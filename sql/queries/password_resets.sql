@@ -0,0 +1,16 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets(id, token_hash, user_id, expires_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, NOW());
+
+-- name: UsePasswordReset :one
+UPDATE password_resets SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: ExpirePasswordResets :exec
+UPDATE password_resets SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: UpdateUserPassword :exec
+UPDATE users SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
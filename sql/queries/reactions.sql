@@ -0,0 +1,19 @@
+-- name: AddReaction :exec
+INSERT INTO chirp_reactions(user_id, chirp_id, reaction, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveReaction :exec
+DELETE FROM chirp_reactions
+WHERE user_id = $1 AND chirp_id = $2 AND reaction = $3;
+
+-- name: CountReactions :many
+SELECT chirp_id, reaction, COUNT(*) AS reaction_count
+FROM chirp_reactions
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+GROUP BY chirp_id, reaction;
+
+-- name: GetUserReactions :many
+SELECT chirp_id, reaction
+FROM chirp_reactions
+WHERE user_id = sqlc.arg('user_id') AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
@@ -0,0 +1,26 @@
+-- name: GetRefreshTokenForUpdate :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: RotateRefreshToken :exec
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW(),
+replaced_by = $2
+WHERE token_hash = $1;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: ListLegacyRefreshTokens :many
+SELECT token_hash, replaced_by FROM refresh_tokens
+WHERE hash_version = 0
+LIMIT $1;
+
+-- name: UpgradeRefreshTokenHash :exec
+UPDATE refresh_tokens SET token_hash = sqlc.arg('new_token_hash'),
+replaced_by = sqlc.arg('replaced_by'),
+hash_version = 1
+WHERE token_hash = sqlc.arg('old_token_hash') AND hash_version = 0;
@@ -0,0 +1,42 @@
+-- name: CreateSession :one
+INSERT INTO sessions(id, user_id, user_agent, ip, created_at, last_used_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    NOW(),
+    NOW(),
+    $4
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions SET last_used_at = NOW(),
+user_agent = $2,
+ip = $3,
+expires_at = $4
+WHERE id = $1;
+
+-- name: ListActiveSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY last_used_at DESC, id;
+
+-- name: RevokeSession :execrows
+UPDATE sessions SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeSessionByTokenHash :exec
+UPDATE sessions SET revoked_at = NOW()
+WHERE id IN (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)
+AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE sessions SET revoked_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND id <> sqlc.arg('keep_id') AND revoked_at IS NULL;
+
+-- name: RevokeOtherRefreshTokens :exec
+UPDATE refresh_tokens SET revoked_at = NOW(),
+updated_at = NOW()
+WHERE user_id = sqlc.arg('user_id') AND family_id <> sqlc.arg('keep_id') AND revoked_at IS NULL;
@@ -13,13 +13,17 @@ RETURNING *;
 DELETE FROM users;
 
 -- name: CreateChirp :one
-INSERT INTO chirps(id,created_at,updated_at,body,user_id)
+INSERT INTO chirps(id,created_at,updated_at,body,user_id,parent_id,root_id,kind,original_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     now(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
 RETURNING *;
 
@@ -30,31 +34,32 @@ SELECT * FROM chirps ORDER BY created_at ASC;
 SELECT * FROM chirps WHERE id = $1;
 
 -- name: GetChirpsByUserId :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+SELECT * FROM chirps WHERE user_id = sqlc.arg('user_id')::uuid ORDER BY created_at ASC;
 
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1;
 
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens(token,user_id,expires_at,revoked_at)
+INSERT INTO refresh_tokens(token_hash,user_id,family_id,expires_at,revoked_at)
 VALUES(
     $1,
     $2,
     $3,
+    $4,
     NULL
 )
 RETURNING *;
 
 
 -- name: GetUserFromRefreshToken :one
-SELECT user_id from refresh_tokens where token = $1 AND revoked_at IS NULL;
+SELECT user_id from refresh_tokens where token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW();
 
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens SET revoked_at = NOW(),
 updated_at = NOW()
-WHERE token = $1;
+WHERE token_hash = $1;
 
 
 -- name: UpdateUserDetails :one
@@ -67,4 +72,51 @@ DELETE FROM chirps WHERE id = $1;
 
 -- name: UpgradeUser :exec
 UPDATE users SET is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: UpdateUserRole :one
+UPDATE users SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE (sqlc.narg('query')::text IS NULL OR email ILIKE '%' || sqlc.narg('query')::text || '%')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: CountChirpsByUser :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = sqlc.arg('user_id')::uuid AND deleted_at IS NULL;
+
+-- name: SuspendUser :one
+UPDATE users SET suspended_at = NOW(),
+suspended_until = $2,
+suspension_reason = $3,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users SET suspended_at = NULL,
+suspended_until = NULL,
+suspension_reason = NULL,
+updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetChirpIDsByUserNewestFirst :many
+SELECT id FROM chirps
+WHERE user_id = sqlc.arg('user_id')::uuid
+ORDER BY created_at DESC, id DESC;
+
+-- name: DeleteUser :execrows
+DELETE FROM users WHERE id = $1;
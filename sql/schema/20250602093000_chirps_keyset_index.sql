@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE INDEX IF NOT EXISTS chirps_created_at_id_idx ON chirps (created_at, id);
+CREATE INDEX IF NOT EXISTS chirps_user_id_created_at_id_idx ON chirps (user_id, created_at, id);
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP INDEX IF EXISTS chirps_user_id_created_at_id_idx;
+DROP INDEX IF EXISTS chirps_created_at_id_idx;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE TABLE chirp_reactions(
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    reaction TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id, reaction),
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_chirp FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE,
+    CONSTRAINT valid_reaction CHECK (reaction IN ('like', 'love', 'laugh', 'wow', 'sad', 'angry'))
+);
+CREATE INDEX chirp_reactions_chirp_id_reaction_idx ON chirp_reactions (chirp_id, reaction);
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE chirp_reactions;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+ALTER TABLE chirps ADD COLUMN kind TEXT NOT NULL DEFAULT 'chirp'
+    CHECK (kind IN ('chirp', 'rechirp', 'quote'));
+ALTER TABLE chirps ADD COLUMN original_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+-- one pure rechirp per user per chirp; quotes are not limited
+CREATE UNIQUE INDEX chirps_one_rechirp_per_user_idx ON chirps (user_id, original_id) WHERE kind = 'rechirp';
+CREATE INDEX chirps_original_id_idx ON chirps (original_id) WHERE original_id IS NOT NULL;
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP INDEX IF EXISTS chirps_original_id_idx;
+DROP INDEX IF EXISTS chirps_one_rechirp_per_user_idx;
+ALTER TABLE chirps DROP COLUMN original_id;
+ALTER TABLE chirps DROP COLUMN kind;
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE TABLE moderation_rules(
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('word', 'regex')),
+    pattern TEXT NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE moderation_flags(
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL,
+    rule_kind TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    matched TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    resolved_at TIMESTAMP,
+    CONSTRAINT fk_chirp FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+CREATE INDEX moderation_flags_open_idx ON moderation_flags (created_at) WHERE resolved_at IS NULL;
+
+-- the words validateChirp used to hard-code
+INSERT INTO moderation_rules(id, kind, pattern, action, created_at)
+VALUES
+    (gen_random_uuid(), 'word', 'kerfuffle', 'mask', NOW()),
+    (gen_random_uuid(), 'word', 'sharbert', 'mask', NOW()),
+    (gen_random_uuid(), 'word', 'fornax', 'mask', NOW());
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE moderation_flags;
+DROP TABLE moderation_rules;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- refresh tokens are stored as HMAC-SHA256(key, token). The key lives in
+-- the app, so existing rows are marked hash_version 0 and re-hashed by the
+-- server at startup.
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+ALTER TABLE refresh_tokens ADD COLUMN hash_version INTEGER NOT NULL DEFAULT 1;
+UPDATE refresh_tokens SET hash_version = 0;
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+-- hashes can't be reversed; only never-migrated rows keep working
+DELETE FROM refresh_tokens WHERE hash_version <> 0;
+ALTER TABLE refresh_tokens DROP COLUMN hash_version;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- a session is one login on one device; its id is the family_id of the
+-- refresh tokens rotated within it
+CREATE TABLE sessions(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX sessions_user_idx ON sessions (user_id, last_used_at);
+
+INSERT INTO sessions(id, user_id, created_at, last_used_at, expires_at, revoked_at)
+SELECT
+    family_id,
+    user_id,
+    MIN(COALESCE(created_at, NOW())),
+    MAX(COALESCE(updated_at, created_at, NOW())),
+    MAX(expires_at),
+    CASE WHEN bool_and(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens ADD CONSTRAINT fk_session
+    FOREIGN KEY (family_id) REFERENCES sessions(id) ON DELETE CASCADE;
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+ALTER TABLE refresh_tokens DROP CONSTRAINT fk_session;
+DROP TABLE sessions;
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE TABLE oauth_clients(
+    id UUID PRIMARY KEY,
+    client_id TEXT NOT NULL UNIQUE,
+    -- NULL for public clients, such as mobile apps, that can't keep a
+    -- secret and rely on PKCE alone
+    client_secret_hash TEXT,
+    name TEXT NOT NULL,
+    redirect_uris TEXT[] NOT NULL,
+    owner_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    CONSTRAINT fk_owner FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX oauth_clients_owner_idx ON oauth_clients (owner_id);
+
+-- a user's consent to a client; revoking it stops every token issued
+-- under it
+CREATE TABLE oauth_grants(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    client_id UUID NOT NULL,
+    scopes TEXT[] NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT fk_client FOREIGN KEY (client_id) REFERENCES oauth_clients(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX oauth_grants_active_idx ON oauth_grants (user_id, client_id) WHERE revoked_at IS NULL;
+
+CREATE TABLE oauth_authorization_codes(
+    id UUID PRIMARY KEY,
+    code_hash TEXT NOT NULL UNIQUE,
+    grant_id UUID NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL,
+    code_challenge TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_grant FOREIGN KEY (grant_id) REFERENCES oauth_grants(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oauth_refresh_tokens(
+    id UUID PRIMARY KEY,
+    token_hash TEXT NOT NULL UNIQUE,
+    grant_id UUID NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    CONSTRAINT fk_grant FOREIGN KEY (grant_id) REFERENCES oauth_grants(id) ON DELETE CASCADE
+);
+CREATE INDEX oauth_refresh_tokens_grant_idx ON oauth_refresh_tokens (grant_id);
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE oauth_refresh_tokens;
+DROP TABLE oauth_authorization_codes;
+DROP TABLE oauth_grants;
+DROP TABLE oauth_clients;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- emails are now stored lowercased. An address that would clash with
+-- another account's is left alone for an admin to sort out.
+UPDATE users SET email = lower(email)
+WHERE email <> lower(email)
+  AND NOT EXISTS (
+    SELECT 1 FROM users other
+    WHERE lower(other.email) = lower(users.email) AND other.id <> users.id
+  );
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- deleting a user deletes their chirps first, except ones with replies,
+-- which stay as tombstones without an author so the threads keep their shape
+ALTER TABLE chirps ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE chirps DROP CONSTRAINT fk_user;
+ALTER TABLE chirps ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DELETE FROM chirps WHERE user_id IS NULL;
+ALTER TABLE chirps DROP CONSTRAINT fk_user;
+ALTER TABLE chirps ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE chirps ALTER COLUMN user_id SET NOT NULL;